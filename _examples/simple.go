@@ -16,19 +16,8 @@ func main() {
 		out.WriteString(srv.Info().String())
 		return nil
 	})
-	srv.HandleFunc("client", func(out *redeo.Responder, req *redeo.Request) error {
-		if len(req.Args) != 1 {
-			return req.WrongNumberOfArgs()
-		}
 
-		switch req.Args[0] {
-		case "list":
-			out.WriteString(srv.Info().ClientsString())
-		default:
-			return req.UnknownCommand()
-		}
-		return nil
-	})
+	srv.EnableClientCommands()
 
 	log.Printf("Listening on tcp://%s", srv.Addr())
 	log.Fatal(srv.ListenAndServe())
@@ -0,0 +1,537 @@
+package redeo
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum number of ACL log entries
+const aclLogMaxLen = 128
+
+// ACL implements per-user access control. It authenticates users and
+// restricts the commands, command categories and keys they can access.
+// ACLs are enabled via Server.UseACL.
+type ACL struct {
+	users map[string]*aclUser
+	log   []*aclLogEntry
+	mutex sync.RWMutex
+}
+
+// NewACL creates a new ACL with a "default" user which requires no
+// password and has access to all commands and keys
+func NewACL() *ACL {
+	acl := &ACL{users: make(map[string]*aclUser)}
+	_ = acl.SetUser("default", "on", "nopass", "allkeys", "allcommands")
+	return acl
+}
+
+// Authenticate implements Authenticator
+func (a *ACL) Authenticate(username, password string) bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	user, ok := a.users[username]
+	if !ok || !user.enabled {
+		return false
+	}
+	if user.nopass {
+		return true
+	}
+
+	hash := hashPassword(password)
+	for _, h := range user.passwords {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUser creates or modifies a user, applying the rules in order. Rules
+// follow the redis ACL SETUSER syntax:
+//
+//	on, off                   enable or disable the user
+//	nopass, resetpass         allow any password, or reset all passwords
+//	>password, <password      add or remove a password
+//	#hash, !hash              add or remove a hex SHA-256 password hash
+//	~pattern                  allow access to keys matching a glob pattern
+//	allkeys, resetkeys        alias for ~*, or remove all key patterns
+//	+command, -command        allow or deny a command, or command|subcommand
+//	+@category, -@category    allow or deny all commands in a category
+//	allcommands, nocommands   alias for +@all and -@all
+//	reset                     remove all permissions and passwords, disable
+//
+// The user is left unchanged when a rule is invalid.
+func (a *ACL) SetUser(name string, rules ...string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	user := &aclUser{name: name}
+	if existing, ok := a.users[name]; ok {
+		user = existing.clone()
+	}
+	for _, rule := range rules {
+		if err := user.apply(rule); err != nil {
+			return err
+		}
+	}
+
+	a.users[name] = user
+	return nil
+}
+
+// DelUser removes users, returns the number of users removed.
+// The default user cannot be removed.
+func (a *ACL) DelUser(names ...string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	n := 0
+	for _, name := range names {
+		if _, ok := a.users[name]; ok && name != "default" {
+			delete(a.users, name)
+			n++
+		}
+	}
+	return n
+}
+
+// Users returns the sorted user names
+func (a *ACL) Users() []string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List describes all users, using the ACL LIST format
+func (a *ACL) List() []string {
+	names := a.Users()
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		if user, ok := a.users[name]; ok {
+			list = append(list, user.String())
+		}
+	}
+	return list
+}
+
+// authorize checks if the user is permitted to run the command with the
+// request arguments. Returns a denial reason ("command" or "key") and the
+// denied object.
+func (a *ACL) authorize(username string, cmd *command, req *Request) (reason, object string) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	info := &cmd.info
+	argc := req.NumArgs()
+	sub := ""
+	if cmd.subs != nil && argc != 0 {
+		sub = strings.ToLower(string(req.Arg(0)))
+	}
+
+	user, ok := a.users[username]
+	if !ok || !user.enabled || !user.canRun(info, sub) {
+		if sub != "" {
+			return "command", info.Name + "|" + sub
+		}
+		return "command", info.Name
+	}
+
+	if info.FirstKey < 1 || info.KeyStep < 1 {
+		return "", ""
+	}
+
+	last := info.LastKey
+	if last < 0 {
+		last += argc + 1
+	}
+	for pos := info.FirstKey; pos <= last && pos <= argc; pos += info.KeyStep {
+		if key := string(req.Arg(pos - 1)); !user.canAccess(key) {
+			return "key", key
+		}
+	}
+	return "", ""
+}
+
+// logDenied records an ACL failure
+func (a *ACL) logDenied(reason, object, username, clientInfo string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	for i, e := range a.log {
+		if e.reason == reason && e.object == object && e.username == username {
+			e.count++
+			e.updated = now
+			e.clientInfo = clientInfo
+
+			copy(a.log[1:i+1], a.log[:i])
+			a.log[0] = e
+			return
+		}
+	}
+
+	entry := &aclLogEntry{
+		count:      1,
+		reason:     reason,
+		object:     object,
+		username:   username,
+		clientInfo: clientInfo,
+		created:    now,
+		updated:    now,
+	}
+	a.log = append([]*aclLogEntry{entry}, a.log...)
+	if len(a.log) > aclLogMaxLen {
+		a.log = a.log[:aclLogMaxLen]
+	}
+}
+
+// recentLog returns up to n of the most recent log entries
+func (a *ACL) recentLog(n int) []aclLogEntry {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if n > len(a.log) {
+		n = len(a.log)
+	}
+	entries := make([]aclLogEntry, n)
+	for i := range entries {
+		entries[i] = *a.log[i]
+	}
+	return entries
+}
+
+// resetLog clears the log
+func (a *ACL) resetLog() {
+	a.mutex.Lock()
+	a.log = a.log[:0]
+	a.mutex.Unlock()
+}
+
+// ------------------------------------------------------------------------
+
+type aclLogEntry struct {
+	count      int
+	reason     string
+	object     string
+	username   string
+	clientInfo string
+	created    time.Time
+	updated    time.Time
+}
+
+type aclUser struct {
+	name      string
+	enabled   bool
+	nopass    bool
+	passwords []string
+	keys      []string
+	commands  []string
+}
+
+func (u *aclUser) clone() *aclUser {
+	dup := *u
+	dup.passwords = append([]string(nil), u.passwords...)
+	dup.keys = append([]string(nil), u.keys...)
+	dup.commands = append([]string(nil), u.commands...)
+	return &dup
+}
+
+func (u *aclUser) apply(rule string) error {
+	switch lower := strings.ToLower(rule); lower {
+	case "on":
+		u.enabled = true
+	case "off":
+		u.enabled = false
+	case "nopass":
+		u.nopass = true
+		u.passwords = nil
+	case "resetpass":
+		u.nopass = false
+		u.passwords = nil
+	case "allkeys":
+		u.keys = []string{"*"}
+	case "resetkeys":
+		u.keys = nil
+	case "allcommands", "+@all":
+		u.commands = []string{"+@all"}
+	case "nocommands", "-@all":
+		u.commands = nil
+	case "reset":
+		*u = aclUser{name: u.name}
+	default:
+		if len(rule) < 2 {
+			return aclRuleError{rule: rule, reason: "Syntax error"}
+		}
+
+		switch rule[0] {
+		case '>':
+			u.addPassword(hashPassword(rule[1:]))
+		case '<':
+			u.removePassword(hashPassword(rule[1:]))
+		case '#':
+			hash := strings.ToLower(rule[1:])
+			if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+				return aclRuleError{rule: rule, reason: "The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters"}
+			}
+			u.addPassword(hash)
+		case '!':
+			u.removePassword(strings.ToLower(rule[1:]))
+		case '~':
+			u.keys = append(u.keys, rule[1:])
+		case '+', '-':
+			u.commands = append(u.commands, lower)
+		default:
+			return aclRuleError{rule: rule, reason: "Syntax error"}
+		}
+	}
+	return nil
+}
+
+func (u *aclUser) addPassword(hash string) {
+	u.nopass = false
+	u.removePassword(hash)
+	u.passwords = append(u.passwords, hash)
+}
+
+func (u *aclUser) removePassword(hash string) {
+	for i, h := range u.passwords {
+		if h == hash {
+			u.passwords = append(u.passwords[:i], u.passwords[i+1:]...)
+			return
+		}
+	}
+}
+
+// canRun evaluates the command rules in order, the last matching rule wins
+func (u *aclUser) canRun(info *CommandInfo, sub string) bool {
+	allowed := false
+	for _, rule := range u.commands {
+		if aclRuleMatches(rule[1:], info, sub) {
+			allowed = rule[0] == '+'
+		}
+	}
+	return allowed
+}
+
+func (u *aclUser) canAccess(key string) bool {
+	for _, pattern := range u.keys {
+		if matchGlob(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the user in the ACL LIST format
+func (u *aclUser) String() string {
+	parts := []string{"user", u.name, "off"}
+	if u.enabled {
+		parts[2] = "on"
+	}
+
+	if u.nopass {
+		parts = append(parts, "nopass")
+	}
+	for _, hash := range u.passwords {
+		parts = append(parts, "#"+hash)
+	}
+
+	if len(u.keys) == 0 {
+		parts = append(parts, "resetkeys")
+	}
+	for _, pattern := range u.keys {
+		parts = append(parts, "~"+pattern)
+	}
+
+	if len(u.commands) == 0 || u.commands[0] != "+@all" {
+		parts = append(parts, "-@all")
+	}
+	parts = append(parts, u.commands...)
+	return strings.Join(parts, " ")
+}
+
+// aclRuleMatches returns true if a rule name (without the +/- prefix)
+// matches a command
+func aclRuleMatches(name string, info *CommandInfo, sub string) bool {
+	if strings.HasPrefix(name, "@") {
+		if name == "@all" {
+			return true
+		}
+		for _, cat := range info.categories() {
+			if cat == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	if i := strings.IndexByte(name, '|'); i > -1 {
+		return name[:i] == info.Name && name[i+1:] == sub
+	}
+	return name == info.Name
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+type aclRuleError struct {
+	rule, reason string
+}
+
+func (e aclRuleError) Error() string {
+	return "redeo: invalid ACL rule '" + e.rule + "': " + e.reason
+}
+
+// ------------------------------------------------------------------------
+
+// UseACL enables access control lists. Clients are authenticated as the
+// "default" user on connect, unless it is disabled or requires a password.
+// Enables the built-in ACL command.
+// Not thread-safe, don't call from multiple goroutines
+func (srv *Server) UseACL(acl *ACL) {
+	srv.SetAuthenticator(acl)
+	srv.acl = acl
+	srv.builtin(CommandInfo{
+		Name:       "acl",
+		Arity:      -2,
+		Flags:      FlagAdmin | FlagNoScript,
+		Categories: []string{"admin", "slow", "dangerous"},
+	}, srv.aclRouter())
+}
+
+// aclRouter creates the ACL subcommand router
+func (srv *Server) aclRouter() *SubCommands {
+	subs := NewSubCommands()
+	subs.HandleCommand(CommandInfo{
+		Name:    "whoami",
+		Arity:   2,
+		Summary: "Return the current connection username.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if req.client == nil {
+			return errNoClient
+		}
+		out.WriteString(req.client.User())
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "users",
+		Arity:   2,
+		Summary: "List all the registered usernames.",
+	}, HandlerFunc(func(out *Responder, _ *Request) error {
+		out.WriteStringBulk(srv.acl.Users())
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "list",
+		Arity:   2,
+		Summary: "List all users in ACL format.",
+	}, HandlerFunc(func(out *Responder, _ *Request) error {
+		out.WriteStringBulk(srv.acl.List())
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "setuser",
+		Arity:   -3,
+		Summary: "Create or modify a user with the specified attributes.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if err := srv.acl.SetUser(req.Args[0], req.Args[1:]...); err != nil {
+			if e, ok := err.(aclRuleError); ok {
+				out.WriteErrorString("ERR Error in ACL SETUSER modifier '" + e.rule + "': " + e.reason)
+				return nil
+			}
+			return err
+		}
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "deluser",
+		Arity:   -3,
+		Summary: "Delete a list of users, and disconnect clients authenticated as these users.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		for _, name := range req.Args {
+			if name == "default" {
+				return ClientError("The 'default' user cannot be removed")
+			}
+		}
+
+		out.WriteInt(srv.acl.DelUser(req.Args...))
+		srv.disconnectUsers(req.client, req.Args)
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "log",
+		Arity:   -2,
+		Summary: "List latest events denied because of ACLs in place. Use RESET to clear the log.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		n := aclLogMaxLen
+		if len(req.Args) == 1 {
+			if strings.ToLower(req.Args[0]) == "reset" {
+				srv.acl.resetLog()
+				return nil
+			}
+
+			var err error
+			if n, err = strconv.Atoi(req.Args[0]); err != nil || n < 0 {
+				return ClientError("value is out of range, must be positive")
+			}
+		} else if len(req.Args) > 1 {
+			return WrongNumberOfArgs("acl|log")
+		}
+
+		now := time.Now()
+		entries := srv.acl.recentLog(n)
+		out.WriteBulkLen(len(entries))
+		for _, e := range entries {
+			out.WriteMapLen(7)
+			out.WriteString("count")
+			out.WriteInt(e.count)
+			out.WriteString("reason")
+			out.WriteString(e.reason)
+			out.WriteString("context")
+			out.WriteString("toplevel")
+			out.WriteString("object")
+			out.WriteString(e.object)
+			out.WriteString("username")
+			out.WriteString(e.username)
+			out.WriteString("age-seconds")
+			out.WriteFloat(float64(now.Sub(e.created)/time.Millisecond) / 1000)
+			out.WriteString("client-info")
+			out.WriteString(e.clientInfo)
+		}
+		return nil
+	}))
+	return subs
+}
+
+// Disconnects clients authenticated as one of the users. The current
+// client is closed once its reply has been written
+func (srv *Server) disconnectUsers(current *Client, users []string) {
+	for _, client := range srv.clients.All() {
+		user := client.User()
+		for _, name := range users {
+			if user == name {
+				srv.killClient(current, client)
+				break
+			}
+		}
+	}
+}
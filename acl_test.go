@@ -0,0 +1,130 @@
+package redeo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ACL", func() {
+	var subject *ACL
+
+	BeforeEach(func() {
+		subject = NewACL()
+	})
+
+	It("should have a default user", func() {
+		Expect(subject.Users()).To(Equal([]string{"default"}))
+		Expect(subject.Authenticate("default", "")).To(BeTrue())
+		Expect(subject.Authenticate("default", "any")).To(BeTrue())
+		Expect(subject.List()).To(Equal([]string{"user default on nopass ~* +@all"}))
+	})
+
+	It("should manage users", func() {
+		Expect(subject.SetUser("alice", "on", ">secret", "~cache:*", "+@read", "-debug")).To(Succeed())
+		Expect(subject.Authenticate("alice", "secret")).To(BeTrue())
+		Expect(subject.Authenticate("alice", "wrong")).To(BeFalse())
+		Expect(subject.Authenticate("bob", "secret")).To(BeFalse())
+		Expect(subject.List()).To(ContainElement("user alice on #2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b ~cache:* -@all +@read -debug"))
+
+		Expect(subject.SetUser("alice", "off")).To(Succeed())
+		Expect(subject.Authenticate("alice", "secret")).To(BeFalse())
+
+		Expect(subject.SetUser("alice", "on", "bad")).To(MatchError("redeo: invalid ACL rule 'bad': Syntax error"))
+		Expect(subject.Authenticate("alice", "secret")).To(BeFalse())
+
+		Expect(subject.DelUser("alice", "default", "missing")).To(Equal(1))
+		Expect(subject.Users()).To(Equal([]string{"default"}))
+	})
+
+	It("should authorize commands and keys", func() {
+		get := newCommand(CommandInfo{Name: "get", FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"read"}}, nil)
+		mset := newCommand(CommandInfo{Name: "mset", FirstKey: 1, LastKey: -1, KeyStep: 2, Categories: []string{"write"}}, nil)
+		config := newCommand(CommandInfo{Name: "config"}, NewSubCommands())
+		Expect(subject.SetUser("alice", "on", "nopass", "~cache:*", "+@read", "+mset", "+config|get")).To(Succeed())
+
+		reason, object := subject.authorize("alice", get, &Request{Args: []string{"cache:a"}})
+		Expect(reason).To(BeEmpty())
+		reason, object = subject.authorize("alice", get, &Request{Args: []string{"other"}})
+		Expect(reason).To(Equal("key"))
+		Expect(object).To(Equal("other"))
+
+		reason, _ = subject.authorize("alice", mset, &Request{Args: []string{"cache:a", "1", "cache:b", "2"}})
+		Expect(reason).To(BeEmpty())
+		reason, object = subject.authorize("alice", mset, &Request{Args: []string{"cache:a", "1", "other", "2"}})
+		Expect(reason).To(Equal("key"))
+		Expect(object).To(Equal("other"))
+
+		reason, _ = subject.authorize("alice", config, &Request{Args: []string{"GET", "x"}})
+		Expect(reason).To(BeEmpty())
+		reason, object = subject.authorize("alice", config, &Request{Args: []string{"set", "x"}})
+		Expect(reason).To(Equal("command"))
+		Expect(object).To(Equal("config|set"))
+
+		reason, object = subject.authorize("bob", get, &Request{Args: []string{"cache:a"}})
+		Expect(reason).To(Equal("command"))
+		Expect(object).To(Equal("get"))
+	})
+
+	Describe("server", func() {
+		var srv *Server
+		var client *Client
+
+		BeforeEach(func() {
+			srv = NewServer(nil)
+			srv.UseACL(subject)
+			srv.HandleCommand(CommandInfo{Name: "get", Arity: 2, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"read"}}, HandlerFunc(func(out *Responder, _ *Request) error {
+				out.WriteNil()
+				return nil
+			}))
+
+			client = NewClient(&mockConn{})
+			client.user = srv.defaultUser()
+			srv.clients.Put(client)
+		})
+
+		It("should authenticate as default", func() {
+			Expect(client.User()).To(Equal("default"))
+			Expect(apply(srv, client, "acl", "whoami")).To(Equal("$7\r\ndefault\r\n"))
+		})
+
+		It("should enforce permissions", func() {
+			Expect(apply(srv, client, "acl", "setuser", "alice", "on", ">secret", "~cache:*", "+get")).To(Equal("+OK\r\n"))
+			Expect(apply(srv, client, "auth", "alice", "secret")).To(Equal("+OK\r\n"))
+
+			Expect(apply(srv, client, "get", "cache:a")).To(Equal("$-1\r\n"))
+			Expect(apply(srv, client, "get", "other")).To(Equal("-NOPERM No permissions to access a key\r\n"))
+			Expect(apply(srv, client, "acl", "whoami")).To(Equal("-NOPERM User alice has no permissions to run the 'acl|whoami' command\r\n"))
+
+			Expect(subject.recentLog(10)).To(HaveLen(2))
+			Expect(subject.recentLog(10)[0].reason).To(Equal("command"))
+			Expect(subject.recentLog(10)[1].object).To(Equal("other"))
+		})
+
+		It("should log failures", func() {
+			Expect(apply(srv, client, "auth", "alice", "wrong")).To(HavePrefix("-WRONGPASS"))
+			Expect(apply(srv, client, "auth", "alice", "wrong")).To(HavePrefix("-WRONGPASS"))
+			Expect(apply(srv, client, "acl", "log", "1")).To(MatchRegexp(`^\*1\r\n\*14\r\n\$5\r\ncount\r\n:2\r\n\$6\r\nreason\r\n\$4\r\nauth\r\n`))
+			Expect(apply(srv, client, "acl", "log", "reset")).To(Equal("+OK\r\n"))
+			Expect(apply(srv, client, "acl", "log")).To(Equal("*0\r\n"))
+		})
+
+		It("should list users", func() {
+			Expect(apply(srv, client, "acl", "users")).To(Equal("*1\r\n$7\r\ndefault\r\n"))
+			Expect(apply(srv, client, "acl", "list")).To(Equal("*1\r\n$31\r\nuser default on nopass ~* +@all\r\n"))
+			Expect(apply(srv, client, "acl", "setuser", "bob", "bad")).To(Equal("-ERR Error in ACL SETUSER modifier 'bad': Syntax error\r\n"))
+		})
+
+		It("should delete users and disconnect their clients", func() {
+			conn := &mockConn{}
+			other := NewClient(conn)
+			other.user = "bob"
+			srv.clients.Put(other)
+
+			Expect(apply(srv, client, "acl", "setuser", "bob", "on")).To(Equal("+OK\r\n"))
+			Expect(apply(srv, client, "acl", "deluser", "bob", "carol")).To(Equal(":1\r\n"))
+			Expect(conn.closed).To(BeTrue())
+			Expect(apply(srv, client, "acl", "deluser", "default")).To(Equal("-ERR The 'default' user cannot be removed\r\n"))
+		})
+
+	})
+})
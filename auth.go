@@ -0,0 +1,115 @@
+package redeo
+
+// Authenticator validates client credentials
+type Authenticator interface {
+	// Authenticate returns true if the credentials are valid. Clients
+	// which authenticate with a password only use the "default" username.
+	Authenticate(username, password string) bool
+}
+
+// AuthenticatorFunc is a callback implementing Authenticator
+type AuthenticatorFunc func(username, password string) bool
+
+// Authenticate calls f(username, password)
+func (f AuthenticatorFunc) Authenticate(username, password string) bool {
+	return f(username, password)
+}
+
+// SetAuthenticator requires clients to authenticate via AUTH (or HELLO)
+// before they can run any other commands.
+// Not thread-safe, don't call from multiple goroutines
+func (srv *Server) SetAuthenticator(auth Authenticator) {
+	srv.auth = auth
+}
+
+// ------------------------------------------------------------------------
+
+const (
+	msgNoAuth    = "NOAUTH Authentication required."
+	msgWrongPass = "WRONGPASS invalid username-password pair or user is disabled."
+)
+
+// Returns the user new clients are authenticated as, if any
+func (srv *Server) defaultUser() string {
+	switch {
+	case srv.acl != nil:
+		if srv.acl.Authenticate("default", "") {
+			return "default"
+		}
+		return ""
+	case srv.auth != nil:
+		return ""
+	}
+	return "default"
+}
+
+// Authenticates a client, returns true on success
+func (srv *Server) authenticate(client *Client, username, password string) bool {
+	if !srv.auth.Authenticate(username, password) {
+		if srv.acl != nil {
+			srv.acl.logDenied("auth", "AUTH", username, client.String())
+		}
+		return false
+	}
+
+	client.setUser(username)
+	return true
+}
+
+// Checks if a client is permitted to run the command, returns an error
+// message when access is denied
+func (srv *Server) authorize(client *Client, cmd *command, req *Request) string {
+	if srv.auth == nil || client == nil || cmd.info.Flags&FlagNoAuth != 0 {
+		return ""
+	}
+
+	user := client.User()
+	if user == "" {
+		return msgNoAuth
+	}
+	if srv.acl == nil {
+		return ""
+	}
+
+	switch reason, object := srv.acl.authorize(user, cmd, req); reason {
+	case "command":
+		srv.acl.logDenied(reason, object, user, client.String())
+		return "NOPERM User " + user + " has no permissions to run the '" + object + "' command"
+	case "key":
+		srv.acl.logDenied(reason, object, user, client.String())
+		return "NOPERM No permissions to access a key"
+	}
+	return ""
+}
+
+// serveAuth implements the AUTH [username] password command
+func (srv *Server) serveAuth(out *Responder, req *Request) error {
+	if req.client == nil {
+		return errNoClient
+	} else if len(req.Args) > 2 {
+		return ClientError("syntax error")
+	}
+
+	if srv.auth == nil {
+		out.WriteErrorString("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		return nil
+	}
+
+	username, password := "default", req.Args[0]
+	if len(req.Args) == 2 {
+		username, password = req.Args[0], req.Args[1]
+	}
+	if !srv.authenticate(req.client, username, password) {
+		out.WriteErrorString(msgWrongPass)
+	}
+	return nil
+}
+
+// errorReply creates a handler which replies with an error message, the
+// message is returned as the outcome
+func errorReply(msg string) Handler {
+	return HandlerFunc(func(out *Responder, _ *Request) error {
+		out.WriteErrorString(msg)
+		return ClientError(msg)
+	})
+}
@@ -0,0 +1,65 @@
+package redeo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authentication", func() {
+	var subject *Server
+	var client *Client
+
+	BeforeEach(func() {
+		subject = NewServer(nil)
+		subject.HandleFunc("ping", func(out *Responder, _ *Request) error {
+			out.WriteInlineString("PONG")
+			return nil
+		})
+		client = NewClient(&mockConn{})
+	})
+
+	It("should not require authentication by default", func() {
+		Expect(subject.defaultUser()).To(Equal("default"))
+		Expect(apply(subject, client, "ping")).To(Equal("+PONG\r\n"))
+		Expect(apply(subject, client, "auth", "secret")).To(HavePrefix("-ERR AUTH <password> called without any password configured"))
+	})
+
+	Describe("with authenticator", func() {
+
+		BeforeEach(func() {
+			subject.SetAuthenticator(AuthenticatorFunc(func(user, pass string) bool {
+				return user == "default" && pass == "secret" || user == "alice" && pass == "wonderland"
+			}))
+			client.user = subject.defaultUser()
+		})
+
+		It("should require authentication", func() {
+			Expect(client.User()).To(Equal(""))
+			Expect(apply(subject, client, "ping")).To(Equal("-NOAUTH Authentication required.\r\n"))
+			Expect(apply(subject, client, "unknown")).To(Equal("-ERR unknown command 'unknown'\r\n"))
+		})
+
+		It("should authenticate via AUTH", func() {
+			Expect(apply(subject, client, "auth", "wrong")).To(Equal("-WRONGPASS invalid username-password pair or user is disabled.\r\n"))
+			Expect(apply(subject, client, "auth", "secret")).To(Equal("+OK\r\n"))
+			Expect(client.User()).To(Equal("default"))
+			Expect(apply(subject, client, "ping")).To(Equal("+PONG\r\n"))
+
+			Expect(apply(subject, client, "auth", "alice", "wonderland")).To(Equal("+OK\r\n"))
+			Expect(client.User()).To(Equal("alice"))
+			Expect(apply(subject, client, "auth", "a", "b", "c")).To(Equal("-ERR syntax error\r\n"))
+		})
+
+		It("should authenticate via HELLO", func() {
+			Expect(apply(subject, client, "hello", "3")).To(HavePrefix("-NOAUTH HELLO must be called with the client already authenticated"))
+			Expect(apply(subject, client, "hello", "3", "auth", "alice", "wrong")).To(Equal("-WRONGPASS invalid username-password pair or user is disabled.\r\n"))
+			Expect(client.Protocol()).To(Equal(RESP2))
+
+			Expect(apply(subject, client, "hello", "3", "AUTH", "alice", "wonderland")).To(HavePrefix("%6\r\n"))
+			Expect(client.User()).To(Equal("alice"))
+			Expect(client.Protocol()).To(Equal(RESP3))
+			Expect(apply(subject, client, "hello", "3", "auth", "alice")).To(Equal("-ERR Syntax error in HELLO option 'auth'\r\n"))
+		})
+
+	})
+})
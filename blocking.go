@@ -0,0 +1,279 @@
+package redeo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBlockTimeout is returned by Waiter.Wait when the timeout expires or
+// when the client is unblocked via CLIENT UNBLOCK ... TIMEOUT. When
+// returned by a handler, the client receives a null reply.
+var ErrBlockTimeout = ClientError("block timeout")
+
+// ErrUnblocked is returned by Waiter.Wait when the client is unblocked
+// via CLIENT UNBLOCK ... ERROR. When returned by a handler, the client
+// receives an UNBLOCKED error.
+var ErrUnblocked = ClientError("client unblocked via CLIENT UNBLOCK")
+
+// Waiter parks a client until one of its keys is signalled. Waiters
+// are created via Server.Block and must be closed once the command
+// completes.
+type Waiter struct {
+	reg      *blocking
+	client   *Client
+	keys     []string
+	ctx      context.Context
+	deadline time.Time
+
+	wake  chan string
+	abort chan error
+}
+
+// Wait blocks until one of the keys is signalled and returns the key.
+// Wait may be called repeatedly, the client retains its position in the
+// queue. Returns ErrBlockTimeout when the timeout expires, ErrUnblocked
+// when the client was unblocked or the context error when the client
+// disconnects or the server is closed.
+func (w *Waiter) Wait() (string, error) {
+	var expired <-chan time.Time
+	if !w.deadline.IsZero() && !time.Now().Before(w.deadline) {
+		select {
+		case key := <-w.wake:
+			return key, nil
+		default:
+			return "", ErrBlockTimeout
+		}
+	} else if !w.deadline.IsZero() {
+		timer := time.NewTimer(w.deadline.Sub(time.Now()))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case key := <-w.wake:
+		return key, nil
+	default:
+	}
+
+	// Don't hold back replies to pipelined commands while parked
+	if w.client != nil {
+		w.client.flushReplies()
+	}
+
+	select {
+	case key := <-w.wake:
+		return key, nil
+	case err := <-w.abort:
+		return "", err
+	case <-expired:
+		return "", ErrBlockTimeout
+	case <-w.ctx.Done():
+		return "", w.ctx.Err()
+	}
+}
+
+// Close stops waiting and removes the client from all queues. A signal
+// which was not consumed by Wait is passed on to the next waiter.
+func (w *Waiter) Close() { w.reg.remove(w) }
+
+// Block registers the client's interest in keys, for commands which wait
+// for data to arrive, like BLPOP. To avoid missed signals, handlers
+// should register before checking for data:
+//
+//	w := srv.Block(req, timeout, keys...)
+//	defer w.Close()
+//
+//	for {
+//		if val, ok := pop(keys); ok {
+//			out.WriteString(val)
+//			return nil
+//		}
+//		if _, err := w.Wait(); err != nil {
+//			return err
+//		}
+//	}
+//
+// A zero timeout waits indefinitely. Within MULTI/EXEC transactions,
+// Wait times out immediately unless a signal is pending.
+func (srv *Server) Block(req *Request, timeout time.Duration, keys ...string) *Waiter {
+	w := &Waiter{
+		reg:    srv.blocking,
+		client: req.client,
+		keys:   keys,
+		ctx:    req.Context(),
+		wake:   make(chan string, 1),
+		abort:  make(chan error, 1),
+	}
+	if req.inTx {
+		// Commands never block within transactions
+		w.deadline = time.Now()
+	} else if timeout > 0 {
+		w.deadline = time.Now().Add(timeout)
+	}
+	srv.blocking.add(w)
+	return w
+}
+
+// Signal wakes the longest waiting client blocked on key, which has not
+// been woken yet. Returns false if no client was woken.
+func (srv *Server) Signal(key string) bool { return srv.blocking.signal(key) }
+
+// Unblock aborts the wait of a blocked client. Waiter.Wait returns
+// ErrUnblocked when withError is set, ErrBlockTimeout otherwise. Returns
+// false if the client is not blocked.
+func (srv *Server) Unblock(id uint64, withError bool) bool {
+	err := ErrBlockTimeout
+	if withError {
+		err = ErrUnblocked
+	}
+	return srv.blocking.unblock(id, err)
+}
+
+// ------------------------------------------------------------------------
+
+type blocking struct {
+	keys    map[string][]*Waiter
+	clients map[uint64]*Waiter
+	mutex   sync.Mutex
+}
+
+func newBlocking() *blocking {
+	return &blocking{
+		keys:    make(map[string][]*Waiter),
+		clients: make(map[uint64]*Waiter),
+	}
+}
+
+// Len returns the number of blocked clients
+func (b *blocking) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.clients)
+}
+
+func (b *blocking) add(w *Waiter) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, key := range w.keys {
+		b.keys[key] = append(b.keys[key], w)
+	}
+	if w.client != nil {
+		b.clients[w.client.id] = w
+		w.client.setBlocked(true)
+	}
+}
+
+func (b *blocking) remove(w *Waiter) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, key := range w.keys {
+		queue := b.keys[key]
+		for i, x := range queue {
+			if x == w {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(b.keys, key)
+		} else {
+			b.keys[key] = queue
+		}
+	}
+	if w.client != nil && b.clients[w.client.id] == w {
+		delete(b.clients, w.client.id)
+		w.client.setBlocked(false)
+	}
+
+	// Pass an unconsumed signal on to the next waiter
+	select {
+	case key := <-w.wake:
+		b.wakeFirst(key)
+	default:
+	}
+}
+
+func (b *blocking) signal(key string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.wakeFirst(key)
+}
+
+// Wakes the first waiter on key which has not been woken yet, requires
+// a lock
+func (b *blocking) wakeFirst(key string) bool {
+	for _, w := range b.keys[key] {
+		select {
+		case w.wake <- key:
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+func (b *blocking) unblock(id uint64, err error) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	w, ok := b.clients[id]
+	if !ok {
+		return false
+	}
+
+	select {
+	case w.abort <- err:
+	default:
+	}
+	return true
+}
+
+// ------------------------------------------------------------------------
+
+// Handles CLIENT UNBLOCK client-id [TIMEOUT|ERROR]
+func (srv *Server) serveClientUnblock(out *Responder, req *Request) error {
+	if len(req.Args) > 2 {
+		return WrongNumberOfArgs("client|unblock")
+	}
+
+	id, err := strconv.ParseUint(req.Args[0], 10, 64)
+	if err != nil {
+		return ClientError("value is not an integer or out of range")
+	}
+
+	withError := false
+	if len(req.Args) == 2 {
+		switch strings.ToLower(req.Args[1]) {
+		case "timeout":
+		case "error":
+			withError = true
+		default:
+			return ClientError("CLIENT UNBLOCK reason should be TIMEOUT or ERROR")
+		}
+	}
+
+	if srv.Unblock(id, withError) {
+		out.WriteInt(1)
+	} else {
+		out.WriteInt(0)
+	}
+	return nil
+}
+
+// Routes CLIENT subcommands
+func (srv *Server) clientRouter() *SubCommands {
+	subs := NewSubCommands()
+	subs.HandleCommand(CommandInfo{
+		Name:    "unblock",
+		Arity:   -3,
+		Summary: "Unblock a client blocked in a blocking command from a different connection.",
+	}, HandlerFunc(srv.serveClientUnblock))
+	return subs
+}
@@ -0,0 +1,141 @@
+package redeo
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Blocking", func() {
+	var subject *Server
+
+	var newRequest = func() *Request {
+		client := NewClient(&mockConn{})
+		client.ctx, client.cancel = context.WithCancel(context.Background())
+		return &Request{Name: "blpop", client: client}
+	}
+
+	BeforeEach(func() {
+		subject = NewServer(nil)
+	})
+
+	It("should wake waiters in FIFO order", func() {
+		w1 := subject.Block(newRequest(), 0, "a", "b")
+		defer w1.Close()
+		w2 := subject.Block(newRequest(), 0, "b")
+		defer w2.Close()
+		Expect(subject.Info().BlockedClientsLen()).To(Equal(2))
+		Expect(subject.Info().String()).To(ContainSubstring("blocked_clients:2\n"))
+
+		Expect(subject.Signal("b")).To(BeTrue())
+		Expect(subject.Signal("b")).To(BeTrue())
+		Expect(subject.Signal("b")).To(BeFalse())
+		Expect(subject.Signal("c")).To(BeFalse())
+
+		Expect(w1.Wait()).To(Equal("b"))
+		Expect(w2.Wait()).To(Equal("b"))
+		Expect(subject.Signal("b")).To(BeTrue())
+		Expect(w1.Wait()).To(Equal("b"))
+
+		w1.Close()
+		Expect(subject.Info().BlockedClientsLen()).To(Equal(1))
+		Expect(subject.Signal("a")).To(BeFalse())
+	})
+
+	It("should pass unconsumed signals on when closed", func() {
+		w1 := subject.Block(newRequest(), 0, "a")
+		defer w1.Close()
+		w2 := subject.Block(newRequest(), 0, "a")
+		defer w2.Close()
+
+		Expect(subject.Signal("a")).To(BeTrue())
+		w1.Close()
+		Expect(w2.Wait()).To(Equal("a"))
+		Expect(subject.Signal("a")).To(BeTrue())
+		Expect(subject.Signal("a")).To(BeFalse())
+	})
+
+	It("should time out", func() {
+		w := subject.Block(newRequest(), 10*time.Millisecond, "a")
+		defer w.Close()
+
+		start := time.Now()
+		_, err := w.Wait()
+		Expect(err).To(Equal(ErrBlockTimeout))
+		Expect(time.Since(start)).To(BeNumerically(">=", 10*time.Millisecond))
+	})
+
+	It("should abort on disconnect", func() {
+		req := newRequest()
+		w := subject.Block(req, 0, "a")
+		defer w.Close()
+
+		req.client.Close()
+		_, err := w.Wait()
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+	It("should unblock clients", func() {
+		req := newRequest()
+		w := subject.Block(req, 0, "a")
+		defer w.Close()
+
+		Expect(subject.Unblock(req.client.ID(), true)).To(BeTrue())
+		_, err := w.Wait()
+		Expect(err).To(Equal(ErrUnblocked))
+
+		Expect(subject.Unblock(req.client.ID(), false)).To(BeTrue())
+		_, err = w.Wait()
+		Expect(err).To(Equal(ErrBlockTimeout))
+
+		w.Close()
+		Expect(subject.Unblock(req.client.ID(), false)).To(BeFalse())
+	})
+
+	It("should serve CLIENT UNBLOCK", func() {
+		blocked := newRequest()
+		subject.HandleFunc("blpop", func(out *Responder, req *Request) error {
+			w := subject.Block(req, 0, req.Args...)
+			defer w.Close()
+
+			key, err := w.Wait()
+			if err != nil {
+				return err
+			}
+			out.WriteString(key)
+			return nil
+		})
+
+		replies := make(chan string, 3)
+		go func() {
+			for i := 0; i < 3; i++ {
+				w := &bytes.Buffer{}
+				subject.apply(&Request{Name: "blpop", Args: []string{"a"}, client: blocked.client}, w)
+				replies <- w.String()
+			}
+		}()
+
+		id := strconv.FormatUint(blocked.client.ID(), 10)
+
+		Eventually(subject.Info().BlockedClientsLen).Should(Equal(1))
+		Expect(apply(subject, nil, "client", "unblock", id)).To(Equal(":1\r\n"))
+		Expect(<-replies).To(Equal("$-1\r\n"))
+
+		Eventually(subject.Info().BlockedClientsLen).Should(Equal(1))
+		Expect(apply(subject, nil, "client", "unblock", id, "error")).To(Equal(":1\r\n"))
+		Expect(<-replies).To(Equal("-UNBLOCKED client unblocked via CLIENT UNBLOCK\r\n"))
+
+		Eventually(subject.Info().BlockedClientsLen).Should(Equal(1))
+		Expect(subject.Signal("a")).To(BeTrue())
+		Expect(<-replies).To(Equal("$1\r\na\r\n"))
+
+		Expect(apply(subject, nil, "client", "unblock", id)).To(Equal(":0\r\n"))
+		Expect(apply(subject, nil, "client", "unblock", "x")).To(Equal("-ERR value is not an integer or out of range\r\n"))
+		Expect(apply(subject, nil, "client", "unblock", id, "foo")).To(Equal("-ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR\r\n"))
+	})
+
+})
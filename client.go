@@ -1,34 +1,142 @@
 package redeo
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// ClientFlag describes client states
+type ClientFlag uint32
+
+// Supported client flags
+const (
+	ClientReplica ClientFlag = 1 << iota
+	ClientMonitor
+	ClientPubSub
+	ClientMulti
+	ClientBlocked
+	ClientDirty
+	ClientNoEvict
+)
+
+var clientFlagLetters = []byte("SOPxbde")
+
+// String returns the flags in CLIENT LIST notation, e.g. "Px", or "N"
+// if no flags are set
+func (f ClientFlag) String() string {
+	letters := make([]byte, 0, len(clientFlagLetters))
+	for i, c := range clientFlagLetters {
+		if f&(1<<uint(i)) != 0 {
+			letters = append(letters, c)
+		}
+	}
+	if len(letters) == 0 {
+		return "N"
+	}
+	return string(letters)
+}
+
 type clientSlice []*Client
 
 func (p clientSlice) Len() int           { return len(p) }
 func (p clientSlice) Less(i, j int) bool { return p[i].id < p[j].id }
 func (p clientSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
+// DisconnectReason describes why a client was disconnected
+type DisconnectReason int
+
+// Supported disconnect reasons
+const (
+	// DisconnectEOF is reported when the client closed the connection, the
+	// connection failed or was closed via Client.Close, e.g. on QUIT
+	DisconnectEOF DisconnectReason = iota + 1
+	// DisconnectTimeout is reported for idle, read and write timeouts
+	DisconnectTimeout
+	// DisconnectProtocolError is reported for malformed requests
+	DisconnectProtocolError
+	// DisconnectKilled is reported for clients killed via CLIENT KILL or
+	// ACL DELUSER
+	DisconnectKilled
+	// DisconnectOutputLimit is reported for clients exceeding their output
+	// buffer limit
+	DisconnectOutputLimit
+	// DisconnectShutdown is reported when the server is closed or shut down
+	DisconnectShutdown
+)
+
+var disconnectReasonNames = []string{"", "eof", "timeout", "protocol error", "killed", "output buffer limit", "shutdown"}
+
+// String returns the reason name
+func (r DisconnectReason) String() string {
+	if r > 0 && int(r) < len(disconnectReasonNames) {
+		return disconnectReasonNames[r]
+	}
+	return "unknown"
+}
+
 var clientInc = uint64(0)
 
+// Returned when a client exceeds its output buffer limit
+var errOutputLimit = errors.New("redeo: output buffer limit exceeded")
+
 // A client is the origin of a request
 type Client struct {
 	Ctx interface{}
 
-	id   uint64
-	conn net.Conn
+	id    uint64
+	conn  net.Conn
+	proto int
+	user  string
+	name  string
+	db    int
 
 	firstAccess time.Time
 	lastAccess  time.Time
 	lastCommand string
+	commands    int64
+	qbuf, obl   int
+
+	channels map[string]struct{}
+	patterns map[string]struct{}
 
-	quit  bool
-	mutex sync.Mutex
+	quit      bool
+	blocked   bool
+	replica   bool
+	monitor   bool
+	noEvict   bool
+	replyOff  bool
+	replySkip int
+	mutex     sync.Mutex
+
+	multi    *transaction
+	watching map[string]struct{}
+	dirty    bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	rd     *connReader
+	wr     *connWriter
+	out    *bufio.Writer
+
+	busy     bool
+	closed   bool
+	pending  []byte
+	npending int
+	inflight int
+	flushing chan struct{}
+	wmutex   sync.Mutex
+
+	limits    *OutputBufferLimits
+	softSince time.Time
+	overflow  func()
+	reason    DisconnectReason
 }
 
 // NewClient creates a new client info container
@@ -37,6 +145,7 @@ func NewClient(conn net.Conn) *Client {
 	return &Client{
 		id:          atomic.AddUint64(&clientInc, 1),
 		conn:        conn,
+		proto:       RESP2,
 		firstAccess: now,
 		lastAccess:  now,
 	}
@@ -48,22 +157,143 @@ func (i *Client) ID() uint64 { return i.id }
 // RemoteAddr return the remote client address
 func (i *Client) RemoteAddr() net.Addr { return i.conn.RemoteAddr() }
 
+// LocalAddr return the local server address
+func (i *Client) LocalAddr() net.Addr { return i.conn.LocalAddr() }
+
+// Name returns the connection name, as assigned by CLIENT SETNAME
+func (i *Client) Name() string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.name
+}
+
+// Protocol returns the negotiated protocol version, either RESP2 or RESP3
+func (i *Client) Protocol() int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.proto
+}
+
+// DB returns the selected database index
+func (i *Client) DB() int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.db
+}
+
+// SetDB selects the database index, e.g. from a SELECT handler
+func (i *Client) SetDB(db int) {
+	i.mutex.Lock()
+	i.db = db
+	i.mutex.Unlock()
+}
+
+// SetReplica flags the client as a replica, e.g. from a SYNC handler
+func (i *Client) SetReplica(replica bool) {
+	i.mutex.Lock()
+	i.replica = replica
+	i.mutex.Unlock()
+}
+
+// Flags returns the current client state
+func (i *Client) Flags() ClientFlag {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.flags()
+}
+
+// BytesRead returns the number of bytes received from the client
+func (i *Client) BytesRead() int64 {
+	if i.rd == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&i.rd.n)
+}
+
+// BytesWritten returns the number of bytes sent to the client
+func (i *Client) BytesWritten() int64 {
+	if i.wr == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&i.wr.n)
+}
+
+// TotalCommands returns the number of commands processed for the client
+func (i *Client) TotalCommands() int64 {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.commands
+}
+
+// QueryBufferLen returns the number of received bytes which are
+// buffered but not yet processed
+func (i *Client) QueryBufferLen() int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.qbuf
+}
+
+// OutputBufferLen returns the number of reply and pushed bytes which
+// are buffered but not yet written
+func (i *Client) OutputBufferLen() int {
+	i.mutex.Lock()
+	obl := i.obl
+	i.mutex.Unlock()
+
+	i.wmutex.Lock()
+	defer i.wmutex.Unlock()
+
+	return obl + i.outputLen()
+}
+
+// User returns the name of the authenticated user. Returns an empty
+// string if the client has not authenticated yet
+func (i *Client) User() string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.user
+}
+
 // Close will disconnect as soon as all pending replies have been written
-// to the client
-func (i *Client) Close() { i.quit = true }
+// to the client. Contexts of active requests are cancelled.
+func (i *Client) Close() {
+	i.quit = true
+	if i.cancel != nil {
+		i.cancel()
+	}
+}
 
-// String generates an info string
+// String generates an info string, in CLIENT LIST format
 func (i *Client) String() string {
 	i.mutex.Lock()
-	cmd := i.lastCommand
-	atime := i.lastAccess
+	name, db, user := i.name, i.db, i.user
+	cmd, atime := i.lastCommand, i.lastAccess
+	flags := i.flags()
+	sub, psub := len(i.channels), len(i.patterns)
+	qbuf, obl := i.qbuf, i.obl
+	multi := -1
+	if i.multi != nil {
+		multi = len(i.multi.queue)
+	}
 	i.mutex.Unlock()
 
+	i.wmutex.Lock()
+	oll, omem := i.npending, obl+i.outputLen()
+	i.wmutex.Unlock()
+
 	now := time.Now()
 	age := now.Sub(i.firstAccess) / time.Second
 	idle := now.Sub(atime) / time.Second
 
-	return fmt.Sprintf("id=%d addr=%s age=%d idle=%d cmd=%s", i.id, i.RemoteAddr(), age, idle, cmd)
+	return fmt.Sprintf("id=%d addr=%s laddr=%s fd=-1 name=%s age=%d idle=%d flags=%s db=%d sub=%d psub=%d multi=%d qbuf=%d obl=%d oll=%d omem=%d cmd=%s user=%s",
+		i.id, i.RemoteAddr(), i.LocalAddr(), name, age, idle, flags, db, sub, psub, multi, qbuf, obl, oll, omem, cmd, user)
 }
 
 // ------------------------------------------------------------------------
@@ -71,6 +301,459 @@ func (i *Client) String() string {
 // Instantly closes the underlying socket connection
 func (i *Client) close() error { return i.conn.Close() }
 
+// Returns the client context, watching the connection for disconnects
+// while a command is served
+func (i *Client) context() context.Context {
+	if i.ctx == nil {
+		return context.Background()
+	}
+	if i.rd != nil {
+		i.rd.startBackgroundRead()
+	}
+	return i.ctx
+}
+
+// Switches the protocol version
+func (i *Client) setProtocol(proto int) {
+	i.mutex.Lock()
+	i.proto = proto
+	i.mutex.Unlock()
+}
+
+// Returns the number of channel and pattern subscriptions
+func (i *Client) subscriptions() int {
+	i.mutex.Lock()
+	n := len(i.channels) + len(i.patterns)
+	i.mutex.Unlock()
+	return n
+}
+
+// Closes the underlying connection unless the client is busy receiving
+// or serving a command, or writing pushed messages. Returns true if closed
+func (i *Client) closeIdle(reason DisconnectReason) bool {
+	i.wmutex.Lock()
+	defer i.wmutex.Unlock()
+
+	if i.busy || i.flushing != nil || len(i.pending) != 0 {
+		return false
+	}
+	i.setReason(reason)
+	i.closed = true
+	_ = i.conn.Close()
+	return true
+}
+
+// Writes an out-of-band message to the client. Messages are queued and
+// written in the background, deferred while the client is being served
+// and written once the replies are flushed. Clients exceeding the output
+// buffer limit are disconnected
+func (i *Client) push(p []byte) error {
+	limit := i.outputLimit()
+
+	i.wmutex.Lock()
+	defer i.wmutex.Unlock()
+
+	if i.closed {
+		return nil
+	}
+	if !i.withinLimit(limit, len(p)) {
+		i.setReason(DisconnectOutputLimit)
+		i.closed = true
+		_ = i.conn.Close()
+		if i.overflow != nil {
+			i.overflow()
+		}
+		return errOutputLimit
+	}
+
+	i.pending = append(i.pending, p...)
+	i.npending++
+	if !i.busy {
+		i.startFlush()
+	}
+	return nil
+}
+
+// Marks the client as busy, deferring pushed messages. Waits for
+// messages which are being written. Returns false if the client was
+// closed while idle
+func (i *Client) beginCommand() bool {
+	i.wmutex.Lock()
+	if i.closed {
+		i.wmutex.Unlock()
+		return false
+	}
+	i.busy = true
+	flushing := i.flushing
+	i.wmutex.Unlock()
+
+	if flushing != nil {
+		<-flushing
+	}
+	return true
+}
+
+// Marks the client as idle, flushing buffered replies followed by
+// pending messages
+func (i *Client) endCommand(w *bufio.Writer) error {
+	i.wmutex.Lock()
+	pending := i.pending
+	i.pending, i.npending = nil, 0
+	i.inflight = len(pending)
+	i.wmutex.Unlock()
+
+	if len(pending) != 0 {
+		_, _ = w.Write(pending)
+	}
+	err := w.Flush()
+
+	i.wmutex.Lock()
+	defer i.wmutex.Unlock()
+
+	i.busy = false
+	i.inflight = 0
+	if err == nil {
+		i.startFlush()
+	}
+	return err
+}
+
+// Flushes the buffered replies of pipelined commands, before the
+// current command parks the client
+func (i *Client) flushReplies() {
+	if i.out != nil {
+		_ = i.out.Flush()
+	}
+}
+
+// Starts writing pending messages in the background, requires a lock
+func (i *Client) startFlush() {
+	if i.flushing != nil || i.closed || len(i.pending) == 0 {
+		return
+	}
+
+	i.flushing = make(chan struct{})
+	go i.flushPending(i.flushing)
+}
+
+// Writes pending messages until none are left or the client is busy
+func (i *Client) flushPending(done chan struct{}) {
+	defer close(done)
+
+	var w io.Writer = i.conn
+	if i.wr != nil {
+		w = i.wr
+	}
+
+	var buf []byte
+	for {
+		i.wmutex.Lock()
+		i.inflight = 0
+		if i.busy || i.closed || len(i.pending) == 0 {
+			i.flushing = nil
+			i.wmutex.Unlock()
+			return
+		}
+		buf, i.pending = i.pending, buf[:0]
+		i.inflight, i.npending = len(buf), 0
+		i.wmutex.Unlock()
+
+		if _, err := w.Write(buf); err != nil {
+			i.wmutex.Lock()
+			if isTimeout(err) {
+				i.setReason(DisconnectTimeout)
+			}
+			i.closed = true
+			i.wmutex.Unlock()
+			_ = i.conn.Close()
+		}
+	}
+}
+
+// Records the first disconnect reason, requires a lock
+func (i *Client) setReason(reason DisconnectReason) {
+	if i.reason == 0 {
+		i.reason = reason
+	}
+}
+
+// Records the disconnect reason, unless already known
+func (i *Client) disconnecting(reason DisconnectReason) {
+	i.wmutex.Lock()
+	i.setReason(reason)
+	i.wmutex.Unlock()
+}
+
+// Returns the disconnect reason, if known
+func (i *Client) disconnectReason() DisconnectReason {
+	i.wmutex.Lock()
+	defer i.wmutex.Unlock()
+
+	return i.reason
+}
+
+// Returns the number of buffered output bytes, requires a lock
+func (i *Client) outputLen() int {
+	return len(i.pending) + i.inflight
+}
+
+// Returns the output buffer limit of the client class
+func (i *Client) outputLimit() OutputBufferLimit {
+	if i.limits == nil {
+		return OutputBufferLimit{}
+	}
+
+	flags := i.Flags()
+	if flags&ClientReplica != 0 {
+		return i.limits.Replica
+	} else if flags&(ClientPubSub|ClientMonitor) != 0 {
+		return i.limits.PubSub
+	}
+	return i.limits.Normal
+}
+
+// Reports if n additional output bytes are within the limit, requires
+// a lock. The soft limit is exceeded once the output buffer was
+// continuously above it for longer than the configured duration
+func (i *Client) withinLimit(limit OutputBufferLimit, n int) bool {
+	size := int64(i.outputLen() + n)
+	if limit.Hard > 0 && size > limit.Hard {
+		return false
+	}
+	if limit.Soft > 0 && size > limit.Soft {
+		now := time.Now()
+		if i.softSince.IsZero() {
+			i.softSince = now
+		} else if now.Sub(i.softSince) > limit.SoftDuration {
+			return false
+		}
+	} else {
+		i.softSince = time.Time{}
+	}
+	return true
+}
+
+// Reports if a reply of n bytes is within the output buffer limit
+func (i *Client) reserveOutput(n int) bool {
+	limit := i.outputLimit()
+	if limit == (OutputBufferLimit{}) {
+		return true
+	}
+
+	i.wmutex.Lock()
+	ok := i.withinLimit(limit, n)
+	if !ok {
+		i.setReason(DisconnectOutputLimit)
+	}
+	i.wmutex.Unlock()
+
+	if !ok && i.overflow != nil {
+		i.overflow()
+	}
+	return ok
+}
+
+// Reports if the client has been idle since cutoff. Subscribed, blocked
+// and monitoring clients are never idle
+func (i *Client) idleSince(cutoff time.Time) bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.lastAccess.Before(cutoff) && len(i.channels)+len(i.patterns) == 0 && !i.blocked && !i.monitor
+}
+
+// Starts a transaction, returns false if already started
+func (i *Client) beginMulti() bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.multi != nil {
+		return false
+	}
+	i.multi = new(transaction)
+	return true
+}
+
+// Ends the transaction, returns nil if none was started
+func (i *Client) endMulti() *transaction {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	tx := i.multi
+	i.multi = nil
+	return tx
+}
+
+// Reports if a transaction was started
+func (i *Client) inMulti() bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.multi != nil
+}
+
+// Queues a command, if a transaction was started
+func (i *Client) queueCommand(req *Request) {
+	i.mutex.Lock()
+	if i.multi != nil {
+		i.multi.queue = append(i.multi.queue, req)
+	}
+	i.mutex.Unlock()
+}
+
+// Flags the transaction as failed
+func (i *Client) failMulti() {
+	i.mutex.Lock()
+	if i.multi != nil {
+		i.multi.failed = true
+	}
+	i.mutex.Unlock()
+}
+
+// Adds a watched key
+func (i *Client) watch(key string) {
+	i.mutex.Lock()
+	if i.watching == nil {
+		i.watching = make(map[string]struct{})
+	}
+	i.watching[key] = struct{}{}
+	i.mutex.Unlock()
+}
+
+// Clears watched keys and the dirty flag, returns the watched keys
+func (i *Client) unwatch() []string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	keys := make([]string, 0, len(i.watching))
+	for key := range i.watching {
+		keys = append(keys, key)
+	}
+	i.watching = nil
+	i.dirty = false
+	return keys
+}
+
+// Flags watched keys as modified
+func (i *Client) markDirty() {
+	i.mutex.Lock()
+	i.dirty = true
+	i.mutex.Unlock()
+}
+
+// Reports if watched keys were modified
+func (i *Client) isDirty() bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.dirty
+}
+
+// Sets the connection name
+func (i *Client) setName(name string) {
+	i.mutex.Lock()
+	i.name = name
+	i.mutex.Unlock()
+}
+
+// Returns the client flags, requires a lock
+func (i *Client) flags() ClientFlag {
+	var flags ClientFlag
+	if i.replica {
+		flags |= ClientReplica
+	}
+	if i.monitor {
+		flags |= ClientMonitor
+	}
+	if len(i.channels)+len(i.patterns) != 0 {
+		flags |= ClientPubSub
+	}
+	if i.multi != nil {
+		flags |= ClientMulti
+	}
+	if i.blocked {
+		flags |= ClientBlocked
+	}
+	if i.dirty {
+		flags |= ClientDirty
+	}
+	if i.noEvict {
+		flags |= ClientNoEvict
+	}
+	return flags
+}
+
+// Returns the client type, as used by CLIENT LIST and KILL filters
+func (i *Client) kind() string {
+	flags := i.Flags()
+	if flags&ClientReplica != 0 {
+		return "replica"
+	} else if flags&ClientPubSub != 0 {
+		return "pubsub"
+	}
+	return "normal"
+}
+
+// Switches the reply mode. Replies are suppressed while off, skip
+// suppresses the reply to the current and the next command
+func (i *Client) setReplyMode(mode int) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.replyOff = mode == replyOff
+	i.replySkip = 0
+	if mode == replySkip {
+		i.replySkip = 2
+	}
+}
+
+// Reports if the reply to the current command should be discarded
+func (i *Client) skipReply() bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.replySkip != 0 {
+		i.replySkip--
+		return true
+	}
+	return i.replyOff
+}
+
+// Protects the client from eviction
+func (i *Client) setNoEvict(on bool) {
+	i.mutex.Lock()
+	i.noEvict = on
+	i.mutex.Unlock()
+}
+
+// Marks the client as monitor
+func (i *Client) setMonitor(monitor bool) {
+	i.mutex.Lock()
+	i.monitor = monitor
+	i.mutex.Unlock()
+}
+
+// Marks the client as blocked
+func (i *Client) setBlocked(blocked bool) {
+	i.mutex.Lock()
+	i.blocked = blocked
+	i.mutex.Unlock()
+}
+
+// Marks the client as active
+func (i *Client) touch() {
+	i.mutex.Lock()
+	i.lastAccess = time.Now()
+	i.mutex.Unlock()
+}
+
+// Sets the authenticated user
+func (i *Client) setUser(user string) {
+	i.mutex.Lock()
+	i.user = user
+	i.mutex.Unlock()
+}
+
 // Tracks user command
 func (i *Client) trackCommand(cmd string) {
 	i.mutex.Lock()
@@ -78,4 +761,12 @@ func (i *Client) trackCommand(cmd string) {
 
 	i.lastAccess = time.Now()
 	i.lastCommand = cmd
+	i.commands++
+}
+
+// Tracks buffered input and output
+func (i *Client) trackBuffers(qbuf, obl int) {
+	i.mutex.Lock()
+	i.qbuf, i.obl = qbuf, obl
+	i.mutex.Unlock()
 }
@@ -1,6 +1,9 @@
 package redeo
 
 import (
+	"bufio"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -19,7 +22,114 @@ var _ = Describe("Client", func() {
 
 	It("should generate info string", func() {
 		subject.id = 12
-		Expect(subject.String()).To(Equal(`id=12 addr=1.2.3.4:10001 age=0 idle=0 cmd=`))
+		Expect(subject.String()).To(Equal(`id=12 addr=1.2.3.4:10001 laddr=127.0.0.1:9736 fd=-1 name= age=0 idle=0 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=0 obl=0 oll=0 omem=0 cmd= user=`))
+
+		subject.setName("worker")
+		subject.SetDB(2)
+		subject.setUser("alice")
+		subject.channels = map[string]struct{}{"a": {}, "b": {}}
+		subject.beginMulti()
+		subject.queueCommand(&Request{Name: "get"})
+		subject.trackCommand("get")
+		subject.trackBuffers(3, 4)
+		subject.busy = true
+		Expect(subject.push([]byte("message"))).To(Succeed())
+		Expect(subject.String()).To(Equal(`id=12 addr=1.2.3.4:10001 laddr=127.0.0.1:9736 fd=-1 name=worker age=0 idle=0 flags=Px db=2 sub=2 psub=0 multi=1 qbuf=3 obl=4 oll=1 omem=11 cmd=get user=alice`))
+	})
+
+	It("should report flags", func() {
+		Expect(subject.Flags()).To(Equal(ClientFlag(0)))
+		Expect(subject.Flags().String()).To(Equal("N"))
+
+		subject.SetReplica(true)
+		subject.setBlocked(true)
+		subject.markDirty()
+		subject.setNoEvict(true)
+		Expect(subject.Flags()).To(Equal(ClientReplica | ClientBlocked | ClientDirty | ClientNoEvict))
+		Expect(subject.Flags().String()).To(Equal("Sbde"))
+		Expect(subject.kind()).To(Equal("replica"))
+	})
+
+	It("should name disconnect reasons", func() {
+		Expect(DisconnectEOF.String()).To(Equal("eof"))
+		Expect(DisconnectProtocolError.String()).To(Equal("protocol error"))
+		Expect(DisconnectShutdown.String()).To(Equal("shutdown"))
+		Expect(DisconnectReason(0).String()).To(Equal("unknown"))
+	})
+
+	It("should track stats", func() {
+		Expect(subject.TotalCommands()).To(Equal(int64(0)))
+		subject.trackCommand("get")
+		subject.trackCommand("set")
+		Expect(subject.TotalCommands()).To(Equal(int64(2)))
+
+		subject.trackBuffers(5, 8)
+		Expect(subject.QueryBufferLen()).To(Equal(5))
+		Expect(subject.OutputBufferLen()).To(Equal(8))
+
+		Expect(subject.BytesRead()).To(Equal(int64(0)))
+		Expect(subject.BytesWritten()).To(Equal(int64(0)))
+	})
+
+	It("should not close idle clients with pending pushes", func() {
+		subject.busy = true
+		Expect(subject.push([]byte("+msg\r\n"))).To(Succeed())
+		subject.busy = false
+		Expect(subject.closeIdle(DisconnectShutdown)).To(BeFalse())
+
+		Expect(subject.endCommand(bufio.NewWriter(subject.conn))).To(Succeed())
+		Eventually(func() bool { return subject.closeIdle(DisconnectShutdown) }).Should(BeTrue())
+		Expect(subject.conn.(*mockConn).String()).To(Equal("+msg\r\n"))
+		Expect(subject.disconnectReason()).To(Equal(DisconnectShutdown))
+	})
+
+	Describe("output buffer limits", func() {
+		var overflows int
+
+		BeforeEach(func() {
+			overflows = 0
+			subject.limits = &OutputBufferLimits{
+				Normal: OutputBufferLimit{Hard: 20},
+				PubSub: OutputBufferLimit{Hard: 20, Soft: 10, SoftDuration: 20 * time.Millisecond},
+			}
+			subject.overflow = func() { overflows++ }
+			subject.busy = true
+		})
+
+		It("should disconnect clients above the hard limit", func() {
+			Expect(subject.push(make([]byte, 15))).To(Succeed())
+			Expect(subject.push(make([]byte, 6))).To(Equal(errOutputLimit))
+			Expect(subject.conn.(*mockConn).closed).To(BeTrue())
+			Expect(subject.beginCommand()).To(BeFalse())
+			Expect(overflows).To(Equal(1))
+		})
+
+		It("should disconnect clients above the soft limit for too long", func() {
+			subject.channels = map[string]struct{}{"a": {}}
+			Expect(subject.push(make([]byte, 12))).To(Succeed())
+			Expect(subject.push(make([]byte, 1))).To(Succeed())
+
+			time.Sleep(30 * time.Millisecond)
+			Expect(subject.push(make([]byte, 1))).To(Equal(errOutputLimit))
+			Expect(overflows).To(Equal(1))
+		})
+
+		It("should reset the soft limit", func() {
+			subject.channels = map[string]struct{}{"a": {}}
+			Expect(subject.push(make([]byte, 12))).To(Succeed())
+			Expect(subject.endCommand(bufio.NewWriter(subject.conn))).To(Succeed())
+
+			time.Sleep(30 * time.Millisecond)
+			Expect(subject.reserveOutput(5)).To(BeTrue())
+			Expect(subject.reserveOutput(12)).To(BeTrue())
+			Expect(overflows).To(Equal(0))
+		})
+
+		It("should limit replies", func() {
+			Expect(subject.reserveOutput(20)).To(BeTrue())
+			Expect(subject.reserveOutput(21)).To(BeFalse())
+			Expect(overflows).To(Equal(1))
+		})
 	})
 
 })
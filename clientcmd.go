@@ -0,0 +1,444 @@
+package redeo
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnableClientCommands enables the full built-in CLIENT command family,
+// i.e. ID, INFO, LIST, KILL, SETNAME, GETNAME, PAUSE, UNPAUSE, REPLY and
+// NO-EVICT, in addition to CLIENT UNBLOCK. The CLIENT command itself is
+// never paused, so CLIENT UNPAUSE remains available.
+//
+// CLIENT PAUSE ... WRITE only pauses EXEC and commands registered via
+// HandleCommand with FlagWrite. Commands registered via Handle or
+// HandleFunc carry no flags and are not paused by WRITE pauses.
+// Not thread-safe, don't call from multiple goroutines
+func (srv *Server) EnableClientCommands() {
+	srv.clientCommands(srv.builtins["client"].subs)
+}
+
+// ------------------------------------------------------------------------
+
+// Replied to malformed options
+var errSyntax = ClientError("syntax error")
+
+// Client reply modes
+const (
+	replyOn = iota
+	replyOff
+	replySkip
+)
+
+// pause suspends command processing
+type pause struct {
+	until time.Time
+	all   bool
+	done  chan struct{}
+	mutex sync.Mutex
+}
+
+// Pauses commands until the deadline. Write commands only unless all
+// is set. Extends and upgrades active pauses
+func (p *pause) Set(until time.Time, all bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.done == nil || !time.Now().Before(p.until) {
+		p.done = make(chan struct{})
+		p.all = false
+	}
+	if until.After(p.until) {
+		p.until = until
+	}
+	p.all = p.all || all
+}
+
+// Resumes command processing
+func (p *pause) Clear() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.done != nil {
+		close(p.done)
+		p.done = nil
+	}
+	p.until = time.Time{}
+}
+
+// Reports if a (write) command is paused, returns the deadline
+func (p *pause) paused(write bool) (time.Time, <-chan struct{}, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.done == nil || !time.Now().Before(p.until) || !(p.all || write) {
+		return time.Time{}, nil, false
+	}
+	return p.until, p.done, true
+}
+
+// Waits while commands are paused, returns false if abort is closed first
+func (p *pause) Wait(abort <-chan struct{}, write bool) bool {
+	for {
+		until, done, ok := p.paused(write)
+		if !ok {
+			return true
+		}
+
+		timer := time.NewTimer(until.Sub(time.Now()))
+		select {
+		case <-abort:
+			timer.Stop()
+			return false
+		case <-done:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Waits while clients are paused, returns false if the client
+// disconnected in the meantime
+func (srv *Server) waitUnpaused(req *Request, cmd *command) bool {
+	if req.inTx || cmd.info.Name == "client" {
+		return true
+	}
+
+	write := cmd.info.Flags&FlagWrite != 0 || cmd.info.Name == "exec"
+	if _, _, ok := srv.pause.paused(write); !ok {
+		return true
+	}
+
+	ctx := srv.ctx
+	if req.client != nil {
+		ctx = req.client.context()
+		req.client.flushReplies()
+	}
+	return srv.pause.Wait(ctx.Done(), write)
+}
+
+// Disconnects a client. The current client is closed once its reply
+// has been written
+func (srv *Server) killClient(current, client *Client) {
+	client.disconnecting(DisconnectKilled)
+	if client == current {
+		client.Close()
+	} else {
+		_ = srv.clients.Close(client.id)
+	}
+}
+
+// Lists clients, sorted by ID
+func (srv *Server) clientList() []*Client {
+	list := srv.clients.All()
+	sort.Sort(clientSlice(list))
+	return list
+}
+
+// Validates client types, as accepted by TYPE filters
+func parseClientType(s string) (string, error) {
+	switch typ := strings.ToLower(s); typ {
+	case "normal", "master", "replica", "pubsub":
+		return typ, nil
+	case "slave":
+		return "replica", nil
+	}
+	return "", ClientError("Unknown client type '" + s + "'")
+}
+
+// Parses a client ID
+func parseClientID(s string) (uint64, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || id == 0 {
+		return 0, ClientError("Invalid client ID")
+	}
+	return id, nil
+}
+
+// clientFilter matches clients by criteria
+type clientFilter struct {
+	ids    map[uint64]bool
+	typ    string
+	addr   string
+	laddr  string
+	user   string
+	skipme bool
+}
+
+func (f *clientFilter) Match(current, client *Client) bool {
+	if f.skipme && client == current {
+		return false
+	}
+	if f.ids != nil && !f.ids[client.id] {
+		return false
+	}
+	if f.typ != "" && client.kind() != f.typ {
+		return false
+	}
+	if f.addr != "" && client.RemoteAddr().String() != f.addr {
+		return false
+	}
+	if f.laddr != "" && client.LocalAddr().String() != f.laddr {
+		return false
+	}
+	if f.user != "" && client.User() != f.user {
+		return false
+	}
+	return true
+}
+
+// Replies with client info strings
+func writeClientList(out *Responder, clients []*Client) {
+	str := ""
+	for _, client := range clients {
+		str += client.String() + "\n"
+	}
+	out.WriteString(str)
+}
+
+// clientCommands adds the opt-in CLIENT subcommands
+func (srv *Server) clientCommands(subs *SubCommands) {
+	subs.HandleCommand(CommandInfo{
+		Name:    "id",
+		Arity:   2,
+		Summary: "Return the ID of the current connection.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if req.client == nil {
+			return errNoClient
+		}
+		out.WriteInt(int(req.client.id))
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "info",
+		Arity:   2,
+		Summary: "Return information about the current client connection.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if req.client == nil {
+			return errNoClient
+		}
+		writeClientList(out, []*Client{req.client})
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "list",
+		Arity:   -2,
+		Summary: "Return information about client connections. Options: TYPE (NORMAL|MASTER|REPLICA|PUBSUB), ID id [id ...].",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		filter := new(clientFilter)
+		for i := 0; i < len(req.Args); i++ {
+			switch strings.ToLower(req.Args[i]) {
+			case "type":
+				if i+1 >= len(req.Args) {
+					return errSyntax
+				}
+				typ, err := parseClientType(req.Args[i+1])
+				if err != nil {
+					return err
+				}
+				filter.typ = typ
+				i++
+			case "id":
+				if i+1 >= len(req.Args) {
+					return errSyntax
+				}
+				filter.ids = make(map[uint64]bool)
+				for _, s := range req.Args[i+1:] {
+					id, err := parseClientID(s)
+					if err != nil {
+						return err
+					}
+					filter.ids[id] = true
+				}
+				i = len(req.Args)
+			default:
+				return errSyntax
+			}
+		}
+
+		var clients []*Client
+		for _, client := range srv.clientList() {
+			if filter.Match(req.client, client) {
+				clients = append(clients, client)
+			}
+		}
+		writeClientList(out, clients)
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "kill",
+		Arity:   -3,
+		Summary: "Kill connections. Options: ID id, ADDR ip:port, LADDR ip:port, USER username, TYPE type, SKIPME (YES|NO).",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		// Old style, kill by address
+		if len(req.Args) == 1 {
+			for _, client := range srv.clientList() {
+				if client.RemoteAddr().String() == req.Args[0] {
+					srv.killClient(req.client, client)
+					return nil
+				}
+			}
+			return ClientError("No such client")
+		}
+		if len(req.Args)%2 != 0 {
+			return errSyntax
+		}
+
+		filter := &clientFilter{skipme: true}
+		for i := 0; i < len(req.Args); i += 2 {
+			val := req.Args[i+1]
+			switch strings.ToLower(req.Args[i]) {
+			case "id":
+				id, err := parseClientID(val)
+				if err != nil {
+					return ClientError("client-id should be greater than 0")
+				}
+				filter.ids = map[uint64]bool{id: true}
+			case "type":
+				typ, err := parseClientType(val)
+				if err != nil {
+					return err
+				}
+				filter.typ = typ
+			case "addr":
+				filter.addr = val
+			case "laddr":
+				filter.laddr = val
+			case "user":
+				filter.user = val
+			case "skipme":
+				switch strings.ToLower(val) {
+				case "yes":
+					filter.skipme = true
+				case "no":
+					filter.skipme = false
+				default:
+					return errSyntax
+				}
+			default:
+				return errSyntax
+			}
+		}
+
+		n := 0
+		for _, client := range srv.clientList() {
+			if filter.Match(req.client, client) {
+				srv.killClient(req.client, client)
+				n++
+			}
+		}
+		out.WriteInt(n)
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "setname",
+		Arity:   3,
+		Summary: "Assign the name to the current connection.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if req.client == nil {
+			return errNoClient
+		}
+
+		name := req.Args[0]
+		for i := 0; i < len(name); i++ {
+			if name[i] < '!' || name[i] > '~' {
+				return ClientError("Client names cannot contain spaces, newlines or special characters.")
+			}
+		}
+		req.client.setName(name)
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "getname",
+		Arity:   2,
+		Summary: "Return the name of the current connection.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if req.client == nil {
+			return errNoClient
+		}
+
+		if name := req.client.Name(); name != "" {
+			out.WriteString(name)
+		} else {
+			out.WriteNil()
+		}
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "pause",
+		Arity:   -3,
+		Summary: "Suspend all, or just write, clients for <timeout> milliseconds. Options: WRITE, ALL (default).",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		ms, err := strconv.ParseInt(req.Args[0], 10, 64)
+		if err != nil || ms < 0 {
+			return ClientError("timeout is not an integer or out of range")
+		}
+
+		all := true
+		if len(req.Args) == 2 {
+			switch strings.ToLower(req.Args[1]) {
+			case "all":
+			case "write":
+				all = false
+			default:
+				return errSyntax
+			}
+		} else if len(req.Args) > 2 {
+			return errSyntax
+		}
+
+		srv.pause.Set(time.Now().Add(time.Duration(ms)*time.Millisecond), all)
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "unpause",
+		Arity:   2,
+		Summary: "Stop the current client pause, resuming traffic.",
+	}, HandlerFunc(func(out *Responder, _ *Request) error {
+		srv.pause.Clear()
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "reply",
+		Arity:   3,
+		Summary: "Control the replies sent to the current connection. Options: ON, OFF, SKIP.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if req.client == nil {
+			return errNoClient
+		}
+
+		switch strings.ToLower(req.Args[0]) {
+		case "on":
+			req.client.setReplyMode(replyOn)
+		case "off":
+			req.client.setReplyMode(replyOff)
+		case "skip":
+			req.client.setReplyMode(replySkip)
+		default:
+			return errSyntax
+		}
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "no-evict",
+		Arity:   3,
+		Summary: "Protect the current connection from eviction. Options: ON, OFF.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if req.client == nil {
+			return errNoClient
+		}
+
+		switch strings.ToLower(req.Args[0]) {
+		case "on":
+			req.client.setNoEvict(true)
+		case "off":
+			req.client.setNoEvict(false)
+		default:
+			return errSyntax
+		}
+		return nil
+	}))
+}
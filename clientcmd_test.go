@@ -0,0 +1,172 @@
+package redeo
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CLIENT commands", func() {
+	var subject *Server
+	var c1, c2 *Client
+
+	var newClient = func(port int) *Client {
+		client := NewClient(&mockConn{Port: port})
+		client.ctx, client.cancel = context.WithCancel(context.Background())
+		subject.clients.Put(client)
+		return client
+	}
+
+	var bulk = func(s string) string {
+		return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+	}
+
+	BeforeEach(func() {
+		subject = NewServer(nil)
+		subject.HandleFunc("ping", func(out *Responder, _ *Request) error {
+			out.WriteInlineString("PONG")
+			return nil
+		})
+		subject.HandleCommand(CommandInfo{Name: "set", Arity: 3, Flags: FlagWrite}, HandlerFunc(func(out *Responder, _ *Request) error {
+			return nil
+		}))
+		subject.EnableClientCommands()
+
+		c1, c2 = newClient(10001), newClient(10002)
+	})
+
+	It("should be opt-in", func() {
+		srv := NewServer(nil)
+		w := &bytes.Buffer{}
+		srv.apply(&Request{Name: "client", Args: []string{"id"}, client: c1}, w)
+		Expect(w.String()).To(Equal("-ERR unknown subcommand 'id'. Try CLIENT HELP.\r\n"))
+	})
+
+	It("should return IDs and info", func() {
+		Expect(apply(subject, c1, "client", "id")).To(Equal(":" + strconv.FormatUint(c1.ID(), 10) + "\r\n"))
+		Expect(apply(subject, c2, "client", "info")).To(Equal(bulk(c2.String() + "\n")))
+	})
+
+	It("should list clients", func() {
+		Expect(apply(subject, c1, "client", "list")).To(Equal(bulk(c1.String() + "\n" + c2.String() + "\n")))
+		Expect(apply(subject, c1, "client", "list", "id", strconv.FormatUint(c2.ID(), 10))).To(Equal(bulk(c2.String() + "\n")))
+		Expect(apply(subject, c1, "client", "list", "type", "normal")).To(Equal(bulk(c1.String() + "\n" + c2.String() + "\n")))
+		Expect(apply(subject, c1, "client", "list", "type", "replica")).To(Equal("$0\r\n\r\n"))
+
+		subject.pubsub.Subscribe(c2, "ch")
+		Expect(apply(subject, c1, "client", "list", "TYPE", "PUBSUB")).To(Equal(bulk(c2.String() + "\n")))
+
+		Expect(apply(subject, c1, "client", "list", "type", "bad")).To(Equal("-ERR Unknown client type 'bad'\r\n"))
+		Expect(apply(subject, c1, "client", "list", "id", "x")).To(Equal("-ERR Invalid client ID\r\n"))
+		Expect(apply(subject, c1, "client", "list", "bad")).To(Equal("-ERR syntax error\r\n"))
+	})
+
+	It("should set and get names", func() {
+		Expect(apply(subject, c1, "client", "getname")).To(Equal("$-1\r\n"))
+		Expect(apply(subject, c1, "client", "setname", "conn-1")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, c1, "client", "getname")).To(Equal("$6\r\nconn-1\r\n"))
+		Expect(c1.Name()).To(Equal("conn-1"))
+
+		Expect(apply(subject, c1, "client", "setname", "bad name")).To(Equal("-ERR Client names cannot contain spaces, newlines or special characters.\r\n"))
+		Expect(apply(subject, c1, "client", "setname", "")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, c1, "client", "getname")).To(Equal("$-1\r\n"))
+	})
+
+	It("should kill clients by address", func() {
+		Expect(apply(subject, c1, "client", "kill", "1.2.3.4:10002")).To(Equal("+OK\r\n"))
+		Expect(c2.conn.(*mockConn).closed).To(BeTrue())
+		Expect(subject.clients.Len()).To(Equal(1))
+
+		Expect(apply(subject, c1, "client", "kill", "1.2.3.4:10002")).To(Equal("-ERR No such client\r\n"))
+	})
+
+	It("should kill clients by filter", func() {
+		c3 := newClient(10003)
+		c3.setUser("alice")
+
+		Expect(apply(subject, c1, "client", "kill", "user", "alice")).To(Equal(":1\r\n"))
+		Expect(c3.conn.(*mockConn).closed).To(BeTrue())
+
+		Expect(apply(subject, c1, "client", "kill", "id", strconv.FormatUint(c1.ID(), 10))).To(Equal(":0\r\n"))
+		Expect(apply(subject, c1, "client", "kill", "laddr", "127.0.0.1:9736")).To(Equal(":1\r\n"))
+		Expect(c2.conn.(*mockConn).closed).To(BeTrue())
+		Expect(c1.quit).To(BeFalse())
+
+		Expect(apply(subject, c1, "client", "kill", "addr", "1.2.3.4:10001", "skipme", "no")).To(Equal(":1\r\n"))
+		Expect(c1.quit).To(BeTrue())
+		Expect(c1.conn.(*mockConn).closed).To(BeFalse())
+
+		Expect(apply(subject, c1, "client", "kill", "id", "0")).To(Equal("-ERR client-id should be greater than 0\r\n"))
+		Expect(apply(subject, c1, "client", "kill", "skipme", "maybe")).To(Equal("-ERR syntax error\r\n"))
+		Expect(apply(subject, c1, "client", "kill", "id", "1", "user")).To(Equal("-ERR syntax error\r\n"))
+	})
+
+	It("should pause clients", func() {
+		Expect(apply(subject, c1, "client", "pause", "10000")).To(Equal("+OK\r\n"))
+
+		done := make(chan string, 1)
+		go func() { done <- apply(subject, c2, "ping") }()
+		Consistently(done, "50ms").ShouldNot(Receive())
+
+		Expect(apply(subject, c1, "client", "unpause")).To(Equal("+OK\r\n"))
+		Eventually(done).Should(Receive(Equal("+PONG\r\n")))
+
+		Expect(apply(subject, c1, "client", "pause", "x")).To(Equal("-ERR timeout is not an integer or out of range\r\n"))
+		Expect(apply(subject, c1, "client", "pause", "10", "bad")).To(Equal("-ERR syntax error\r\n"))
+	})
+
+	It("should pause write commands", func() {
+		Expect(apply(subject, c1, "client", "pause", "30", "write")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, c2, "ping")).To(Equal("+PONG\r\n"))
+
+		start := time.Now()
+		Expect(apply(subject, c2, "set", "k", "v")).To(Equal("+OK\r\n"))
+		Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+	})
+
+	It("should only pause commands flagged as writes", func() {
+		subject.HandleFunc("del", func(out *Responder, _ *Request) error {
+			out.WriteInt(1)
+			return nil
+		})
+		Expect(apply(subject, c1, "client", "pause", "10000", "write")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, c2, "del", "k")).To(Equal(":1\r\n"))
+	})
+
+	It("should abort pauses on disconnect", func() {
+		Expect(apply(subject, c1, "client", "pause", "10000")).To(Equal("+OK\r\n"))
+
+		done := make(chan bool, 1)
+		go func() { done <- subject.apply(&Request{Name: "ping", client: c2}, &bytes.Buffer{}) }()
+		Consistently(done, "20ms").ShouldNot(Receive())
+
+		c2.cancel()
+		Eventually(done).Should(Receive(BeFalse()))
+	})
+
+	It("should control replies", func() {
+		Expect(apply(subject, c1, "client", "reply", "off")).To(Equal(""))
+		Expect(apply(subject, c1, "ping")).To(Equal(""))
+		Expect(apply(subject, c1, "client", "reply", "on")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, c1, "ping")).To(Equal("+PONG\r\n"))
+
+		Expect(apply(subject, c1, "client", "reply", "skip")).To(Equal(""))
+		Expect(apply(subject, c1, "ping")).To(Equal(""))
+		Expect(apply(subject, c1, "ping")).To(Equal("+PONG\r\n"))
+
+		Expect(apply(subject, c1, "client", "reply", "bad")).To(Equal("-ERR syntax error\r\n"))
+	})
+
+	It("should toggle no-evict", func() {
+		Expect(apply(subject, c1, "client", "no-evict", "on")).To(Equal("+OK\r\n"))
+		Expect(c1.noEvict).To(BeTrue())
+		Expect(apply(subject, c1, "client", "no-evict", "off")).To(Equal("+OK\r\n"))
+		Expect(c1.noEvict).To(BeFalse())
+		Expect(apply(subject, c1, "client", "no-evict", "bad")).To(Equal("-ERR syntax error\r\n"))
+	})
+
+})
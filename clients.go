@@ -1,30 +1,69 @@
 package redeo
 
-import "sync"
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Replied to connections exceeding the client limits
+var (
+	errMaxClients      = ClientError("max number of clients reached")
+	errMaxClientsPerIP = ClientError("max number of clients per IP reached")
+)
 
 type clients struct {
-	m map[uint64]*Client
-	l sync.Mutex
+	m        map[uint64]*Client
+	reserved map[uint64]*Client
+	ips      map[string]int
+	l        sync.Mutex
 }
 
 func newClientRegistry() *clients {
 	return &clients{
-		m: make(map[uint64]*Client, 10),
+		m:        make(map[uint64]*Client, 10),
+		reserved: make(map[uint64]*Client),
+		ips:      make(map[string]int),
 	}
 }
 
-// Put adds a client connection
+// Put adds a client connection, claiming its reservation if present
 func (c *clients) Put(client *Client) {
 	c.l.Lock()
-	c.m[client.id] = client
+	c.put(client)
 	c.l.Unlock()
 }
 
-// Close removes a client connection
+// Reserve reserves a slot for a client connection, unless the total or
+// the per-IP number of clients would exceed max or maxPerIP (0 to
+// disable). Reserved clients count towards the limits, but are not
+// listed until added via Put
+func (c *clients) Reserve(client *Client, max, maxPerIP int) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if max > 0 && len(c.m)+len(c.reserved) >= max {
+		return errMaxClients
+	}
+	if ip := remoteIP(client.conn); maxPerIP > 0 && ip != "" && c.ips[ip] >= maxPerIP {
+		return errMaxClientsPerIP
+	}
+
+	c.reserved[client.id] = client
+	c.trackIP(client, 1)
+	return nil
+}
+
+// Close removes a client connection or reservation
 func (c *clients) Close(id uint64) error {
 	c.l.Lock()
 	client, ok := c.m[id]
-	delete(c.m, id)
+	if !ok {
+		client, ok = c.reserved[id]
+	}
+	if ok {
+		c.remove(client)
+	}
 	c.l.Unlock()
 
 	if ok {
@@ -38,15 +77,48 @@ func (c *clients) Clear() (err error) {
 	c.l.Lock()
 	defer c.l.Unlock()
 
-	for id, conn := range c.m {
-		if e := conn.close(); e != nil {
-			err = e
+	for _, m := range []map[uint64]*Client{c.m, c.reserved} {
+		for _, client := range m {
+			if e := client.close(); e != nil {
+				err = e
+			}
+			c.remove(client)
 		}
-		delete(c.m, id)
 	}
 	return
 }
 
+// CloseIdle closes all idle client connections, returns the
+// number of remaining, busy or reserved clients
+func (c *clients) CloseIdle() int {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for _, client := range c.m {
+		if client.closeIdle(DisconnectShutdown) {
+			c.remove(client)
+		}
+	}
+	return len(c.m) + len(c.reserved)
+}
+
+// CloseTimedOut closes idle client connections which have not sent
+// a request within the timeout, returns the number of closed clients
+func (c *clients) CloseTimedOut(timeout time.Duration) int {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	cutoff := time.Now().Add(-timeout)
+	n := 0
+	for _, client := range c.m {
+		if client.idleSince(cutoff) && client.closeIdle(DisconnectTimeout) {
+			c.remove(client)
+			n++
+		}
+	}
+	return n
+}
+
 // Len returns the length
 func (c *clients) Len() int {
 	c.l.Lock()
@@ -66,3 +138,49 @@ func (c *clients) All() []*Client {
 	}
 	return slice
 }
+
+// Registers a client, requires a lock
+func (c *clients) put(client *Client) {
+	if _, ok := c.m[client.id]; ok {
+		return
+	}
+	if _, ok := c.reserved[client.id]; ok {
+		delete(c.reserved, client.id)
+	} else {
+		c.trackIP(client, 1)
+	}
+	c.m[client.id] = client
+}
+
+// Unregisters a client or reservation, requires a lock
+func (c *clients) remove(client *Client) {
+	if _, ok := c.m[client.id]; ok {
+		delete(c.m, client.id)
+	} else if _, ok := c.reserved[client.id]; ok {
+		delete(c.reserved, client.id)
+	} else {
+		return
+	}
+	c.trackIP(client, -1)
+}
+
+// Updates the number of clients per IP, requires a lock
+func (c *clients) trackIP(client *Client, delta int) {
+	ip := remoteIP(client.conn)
+	if ip == "" {
+		return
+	}
+	if n := c.ips[ip] + delta; n > 0 {
+		c.ips[ip] = n
+	} else {
+		delete(c.ips, ip)
+	}
+}
+
+// Returns the remote IP of TCP connections
+func remoteIP(conn net.Conn) string {
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
@@ -1,6 +1,8 @@
 package redeo
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -18,6 +20,51 @@ var _ = Describe("clients", func() {
 		Expect(subject.All()).To(HaveLen(1))
 	})
 
+	It("should reserve clients within limits", func() {
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 1}), 3, 2)).To(Succeed())
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 2}), 3, 2)).To(Succeed())
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 3}), 3, 2)).To(Equal(errMaxClientsPerIP))
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 3}), 3, 0)).To(Succeed())
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 4}), 3, 0)).To(Equal(errMaxClients))
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 4}), 0, 0)).To(Succeed())
+		Expect(subject.reserved).To(HaveLen(4))
+		Expect(subject.ips).To(Equal(map[string]int{"1.2.3.4": 4}))
+
+		// Reserved clients are not listed
+		Expect(subject.Len()).To(Equal(0))
+		Expect(subject.All()).To(BeEmpty())
+	})
+
+	It("should register reserved clients", func() {
+		client := NewClient(&mockConn{Port: 1})
+		Expect(subject.Reserve(client, 1, 0)).To(Succeed())
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 2}), 1, 0)).To(Equal(errMaxClients))
+		Expect(subject.CloseIdle()).To(Equal(1))
+
+		subject.Put(client)
+		Expect(subject.Len()).To(Equal(1))
+		Expect(subject.reserved).To(BeEmpty())
+		Expect(subject.ips).To(Equal(map[string]int{"1.2.3.4": 1}))
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 2}), 1, 0)).To(Equal(errMaxClients))
+	})
+
+	It("should track clients per IP", func() {
+		c1, c2 := NewClient(&mockConn{Port: 1}), NewClient(&mockConn{Port: 2})
+		Expect(subject.Reserve(c1, 0, 2)).To(Succeed())
+		Expect(subject.Reserve(c2, 0, 2)).To(Succeed())
+		subject.Put(c2)
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 3}), 0, 2)).To(Equal(errMaxClientsPerIP))
+
+		Expect(subject.Close(c1.id)).To(Succeed())
+		Expect(subject.Close(c1.id)).To(Succeed())
+		Expect(subject.ips).To(Equal(map[string]int{"1.2.3.4": 1}))
+		Expect(subject.Reserve(NewClient(&mockConn{Port: 3}), 0, 2)).To(Succeed())
+
+		Expect(subject.Clear()).To(Succeed())
+		Expect(subject.ips).To(BeEmpty())
+		Expect(subject.reserved).To(BeEmpty())
+	})
+
 	It("should close clients", func() {
 		conn := &mockConn{}
 		client := NewClient(conn)
@@ -44,4 +91,27 @@ var _ = Describe("clients", func() {
 		Expect(subject.m).To(BeEmpty())
 	})
 
+	It("should close timed-out clients", func() {
+		idle, active, subscribed := &mockConn{}, &mockConn{}, &mockConn{}
+		subject.Put(NewClient(idle))
+		subject.Put(NewClient(active))
+		subject.Put(NewClient(subscribed))
+
+		for _, client := range subject.All() {
+			switch client.conn {
+			case idle:
+				client.lastAccess = time.Now().Add(-time.Minute)
+			case subscribed:
+				client.lastAccess = time.Now().Add(-time.Minute)
+				client.channels = map[string]struct{}{"news": {}}
+			}
+		}
+
+		Expect(subject.CloseTimedOut(time.Second)).To(Equal(1))
+		Expect(idle.closed).To(BeTrue())
+		Expect(active.closed).To(BeFalse())
+		Expect(subscribed.closed).To(BeFalse())
+		Expect(subject.Len()).To(Equal(2))
+	})
+
 })
@@ -0,0 +1,300 @@
+package redeo
+
+import (
+	"sort"
+	"strings"
+)
+
+// CommandFlag describes command properties
+type CommandFlag uint32
+
+// Supported command flags
+const (
+	FlagReadOnly CommandFlag = 1 << iota
+	FlagWrite
+	FlagAdmin
+	FlagPubSub
+	FlagNoScript
+	FlagFast
+	FlagBlocking
+	FlagNoAuth
+	FlagSkipMonitor
+)
+
+var commandFlagNames = []string{
+	"readonly",
+	"write",
+	"admin",
+	"pubsub",
+	"noscript",
+	"fast",
+	"blocking",
+	"no_auth",
+	"skip_monitor",
+}
+
+// Strings returns the names of all set flags
+func (f CommandFlag) Strings() []string {
+	names := make([]string, 0, len(commandFlagNames))
+	for i, name := range commandFlagNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// CommandInfo contains command metadata
+type CommandInfo struct {
+	// Name is the command name
+	Name string
+
+	// Arity is the number of arguments, including the command name.
+	// A negative value means at least -Arity arguments. When zero, the
+	// number of arguments is not validated.
+	Arity int
+
+	// Flags describe command properties
+	Flags CommandFlag
+
+	// FirstKey, LastKey and KeyStep describe the argument positions of
+	// keys, where 0 is the command name. A negative LastKey is counted
+	// from the last argument.
+	FirstKey, LastKey, KeyStep int
+
+	// Categories are the ACL categories, e.g. "read" or "@string"
+	Categories []string
+
+	// Summary, Since and Group are returned by COMMAND DOCS
+	Summary, Since, Group string
+
+	// ByteArgs skips the conversion of arguments to Request.Args strings.
+	// Handlers must access arguments via Request.Arg instead, which saves
+	// allocations for commands receiving large values.
+	ByteArgs bool
+
+	// Streaming commands receive their final argument as an io.Reader via
+	// Request.Stream, rather than buffered in memory. Only the final
+	// argument can be streamed as it is the last to arrive on the wire.
+	Streaming bool
+}
+
+// acceptsArgs returns true when n arguments (excluding the
+// command name) satisfy the arity
+func (i *CommandInfo) acceptsArgs(n int) bool {
+	n++
+	if i.Arity < 0 {
+		return n >= -i.Arity
+	}
+	return i.Arity == 0 || n == i.Arity
+}
+
+// arity returns the arity as reported by COMMAND
+func (i *CommandInfo) arity() int {
+	if i.Arity == 0 {
+		return -1
+	}
+	return i.Arity
+}
+
+// categories returns the normalized ACL categories
+func (i *CommandInfo) categories() []string {
+	cats := make([]string, len(i.Categories))
+	for n, cat := range i.Categories {
+		cats[n] = "@" + strings.ToLower(strings.TrimPrefix(cat, "@"))
+	}
+	return cats
+}
+
+// command is a registered handler with metadata
+type command struct {
+	info    CommandInfo
+	handler Handler
+	subs    *SubCommands
+}
+
+func newCommand(info CommandInfo, handler Handler) *command {
+	subs, _ := handler.(*SubCommands)
+	return &command{info: info, handler: handler, subs: subs}
+}
+
+type commandSlice []*command
+
+func (p commandSlice) Len() int           { return len(p) }
+func (p commandSlice) Less(i, j int) bool { return p[i].info.Name < p[j].info.Name }
+func (p commandSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// Replies to commands with an invalid number of arguments
+var wrongNumberOfArgs = HandlerFunc(func(_ *Responder, req *Request) error {
+	return req.WrongNumberOfArgs()
+})
+
+// ------------------------------------------------------------------------
+
+// commandList returns all commands, sorted by name
+func (srv *Server) commandList() []*command {
+	cmds := make([]*command, 0, len(srv.commands)+len(srv.builtins))
+	for name, cmd := range srv.builtins {
+		if _, ok := srv.commands[name]; !ok {
+			cmds = append(cmds, cmd)
+		}
+	}
+	for _, cmd := range srv.commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Sort(commandSlice(cmds))
+	return cmds
+}
+
+// commandRouter creates the COMMAND subcommand router
+func (srv *Server) commandRouter() *SubCommands {
+	subs := NewSubCommands()
+	subs.HandleCommand(CommandInfo{
+		Name:    "count",
+		Arity:   2,
+		Summary: "Return the total number of commands in this server.",
+	}, HandlerFunc(func(out *Responder, _ *Request) error {
+		out.WriteInt(len(srv.commandList()))
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "list",
+		Arity:   2,
+		Summary: "Return a list of all commands in this server.",
+	}, HandlerFunc(func(out *Responder, _ *Request) error {
+		cmds := srv.commandList()
+		out.WriteBulkLen(len(cmds))
+		for _, cmd := range cmds {
+			out.WriteString(cmd.info.Name)
+		}
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "info",
+		Arity:   -2,
+		Summary: "Return details about the specified commands, or all commands if none are given.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		cmds := srv.commandsByName(req.Args)
+		out.WriteBulkLen(len(cmds))
+		for _, cmd := range cmds {
+			if cmd == nil {
+				out.WriteNil()
+			} else {
+				writeCommandInfo(out, cmd)
+			}
+		}
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "docs",
+		Arity:   -2,
+		Summary: "Return documentation details about the specified commands, or all commands if none are given.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		cmds := srv.commandsByName(req.Args)
+		n := 0
+		for _, cmd := range cmds {
+			if cmd != nil {
+				n++
+			}
+		}
+
+		out.WriteMapLen(n)
+		for _, cmd := range cmds {
+			if cmd != nil {
+				out.WriteString(cmd.info.Name)
+				writeCommandDocs(out, &cmd.info)
+			}
+		}
+		return nil
+	}))
+	return subs
+}
+
+// serveCommand implements the COMMAND command
+func (srv *Server) serveCommand(out *Responder, req *Request) error {
+	if len(req.Args) != 0 {
+		return srv.builtins["command"].subs.ServeClient(out, req)
+	}
+
+	cmds := srv.commandList()
+	out.WriteBulkLen(len(cmds))
+	for _, cmd := range cmds {
+		writeCommandInfo(out, cmd)
+	}
+	return nil
+}
+
+// commandsByName looks up commands by name, returns all commands when no
+// names are given. Unknown commands are returned as nil
+func (srv *Server) commandsByName(names []string) []*command {
+	if len(names) == 0 {
+		return srv.commandList()
+	}
+
+	cmds := make([]*command, 0, len(names))
+	for _, name := range names {
+		cmd, _ := srv.lookup(strings.ToLower(name))
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// writeCommandInfo writes command details in the COMMAND INFO format
+func writeCommandInfo(out *Responder, cmd *command) {
+	info := &cmd.info
+
+	out.WriteBulkLen(10)
+	out.WriteString(info.Name)
+	out.WriteInt(info.arity())
+
+	flags := info.Flags.Strings()
+	out.WriteSetLen(len(flags))
+	for _, flag := range flags {
+		out.WriteInlineString(flag)
+	}
+
+	out.WriteInt(info.FirstKey)
+	out.WriteInt(info.LastKey)
+	out.WriteInt(info.KeyStep)
+
+	cats := info.categories()
+	out.WriteSetLen(len(cats))
+	for _, cat := range cats {
+		out.WriteInlineString(cat)
+	}
+
+	out.WriteBulkLen(0) // tips
+	out.WriteBulkLen(0) // key specs
+
+	if cmd.subs == nil {
+		out.WriteBulkLen(0)
+		return
+	}
+
+	list := cmd.subs.list()
+	out.WriteBulkLen(len(list))
+	for _, sub := range list {
+		full := *sub
+		full.info.Name = info.Name + "|" + sub.info.Name
+		writeCommandInfo(out, &full)
+	}
+}
+
+// writeCommandDocs writes command details in the COMMAND DOCS format
+func writeCommandDocs(out *Responder, info *CommandInfo) {
+	docs := make([]string, 0, 6)
+	for _, kv := range [][2]string{
+		{"summary", info.Summary},
+		{"since", info.Since},
+		{"group", info.Group},
+	} {
+		if kv[1] != "" {
+			docs = append(docs, kv[0], kv[1])
+		}
+	}
+
+	out.WriteMapLen(len(docs) / 2)
+	for _, s := range docs {
+		out.WriteString(s)
+	}
+}
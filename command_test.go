@@ -0,0 +1,79 @@
+package redeo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CommandInfo", func() {
+
+	It("should validate arity", func() {
+		Expect((&CommandInfo{Arity: 2}).acceptsArgs(1)).To(BeTrue())
+		Expect((&CommandInfo{Arity: 2}).acceptsArgs(2)).To(BeFalse())
+		Expect((&CommandInfo{Arity: -2}).acceptsArgs(0)).To(BeFalse())
+		Expect((&CommandInfo{Arity: -2}).acceptsArgs(3)).To(BeTrue())
+		Expect((&CommandInfo{}).acceptsArgs(5)).To(BeTrue())
+	})
+
+	It("should convert flags", func() {
+		Expect((FlagReadOnly | FlagFast).Strings()).To(Equal([]string{"readonly", "fast"}))
+		Expect(CommandFlag(0).Strings()).To(BeEmpty())
+	})
+
+})
+
+var _ = Describe("COMMAND", func() {
+	var subject *Server
+
+	BeforeEach(func() {
+		subject = NewServer(nil)
+		subject.builtins = map[string]*command{"command": subject.builtins["command"]}
+		subject.HandleCommand(CommandInfo{
+			Name:       "GET",
+			Arity:      2,
+			Flags:      FlagReadOnly | FlagFast,
+			FirstKey:   1,
+			LastKey:    1,
+			KeyStep:    1,
+			Categories: []string{"read", "@string"},
+			Summary:    "Get the value of a key",
+			Since:      "1.0.0",
+		}, HandlerFunc(func(out *Responder, req *Request) error {
+			out.WriteString(req.Args[0])
+			return nil
+		}))
+	})
+
+	It("should enforce arity", func() {
+		Expect(apply(subject, nil, "get", "x")).To(Equal("$1\r\nx\r\n"))
+		Expect(apply(subject, nil, "get")).To(Equal("-ERR wrong number of arguments for 'get' command\r\n"))
+		Expect(apply(subject, nil, "get", "x", "y")).To(Equal("-ERR wrong number of arguments for 'get' command\r\n"))
+		Expect(subject.Info().TotalCommands()).To(Equal(int64(1)))
+	})
+
+	It("should count", func() {
+		Expect(apply(subject, nil, "command", "count")).To(Equal(":2\r\n"))
+	})
+
+	It("should list", func() {
+		Expect(apply(subject, nil, "command", "list")).To(Equal("*2\r\n$7\r\ncommand\r\n$3\r\nget\r\n"))
+	})
+
+	It("should return info", func() {
+		Expect(apply(subject, nil, "command", "info", "GET", "missing")).To(Equal("*2\r\n" +
+			"*10\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n*2\r\n+@read\r\n+@string\r\n*0\r\n*0\r\n*0\r\n" +
+			"$-1\r\n"))
+		Expect(apply(subject, nil, "command")).To(HavePrefix("*2\r\n*10\r\n$7\r\ncommand\r\n:-1\r\n*1\r\n+fast\r\n"))
+		Expect(apply(subject, nil, "command", "info", "command")).To(ContainSubstring("*4\r\n*10\r\n$13\r\ncommand|count\r\n:2\r\n"))
+	})
+
+	It("should return docs", func() {
+		Expect(apply(subject, nil, "command", "docs", "get")).To(Equal("*2\r\n$3\r\nget\r\n" +
+			"*4\r\n$7\r\nsummary\r\n$22\r\nGet the value of a key\r\n$5\r\nsince\r\n$5\r\n1.0.0\r\n"))
+	})
+
+	It("should reject unknown subcommands", func() {
+		Expect(apply(subject, nil, "command", "x")).To(Equal("-ERR unknown subcommand 'x'. Try COMMAND HELP.\r\n"))
+	})
+
+})
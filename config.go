@@ -1,6 +1,10 @@
 package redeo
 
-import "time"
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
 
 // Server configuration
 type Config struct {
@@ -14,7 +18,35 @@ type Config struct {
 	// on a unix socket when not specified.
 	Socket string
 
-	// Close the connection after a client is idle for N seconds (0 to disable)
+	// Accept TLS connections on the specified address, in addition to
+	// Addr and Socket. Requires TLSConfig. If not specified, server will
+	// not listen for TLS connections.
+	TLSAddr string
+
+	// TLSConfig is used to serve TLS connections. It must contain at least
+	// one certificate. To authenticate clients by certificate (mutual TLS),
+	// set ClientAuth to tls.RequireAndVerifyClientCert and ClientCAs to the
+	// trusted authorities.
+	TLSConfig *tls.Config
+
+	// Close the connection after a client is idle for N seconds (0 to disable).
+	// Like redis, subscribed clients and clients serving a command are never
+	// considered idle.
+	IdleTimeout time.Duration
+
+	// ReadTimeout limits the time to wait for data while a request is
+	// read, including streamed arguments. Unlike IdleTimeout it does not
+	// apply while waiting for the next request (0 to disable).
+	ReadTimeout time.Duration
+
+	// WriteTimeout limits the time to wait for the client to accept
+	// reply data. It applies to every write, so large replies are not cut
+	// off as long as the client keeps reading (0 to disable).
+	WriteTimeout time.Duration
+
+	// Timeout is the former name of IdleTimeout.
+	//
+	// Deprecated: use IdleTimeout instead.
 	Timeout time.Duration
 
 	// If non-zero, use SO_KEEPALIVE to send TCP ACKs to clients in absence
@@ -26,9 +58,143 @@ type Config struct {
 	// Note that to close the connection the double of the time is needed.
 	// On other kernels the period depends on the kernel configuration.
 	TCPKeepAlive time.Duration
+
+	// CommandTimeout sets a deadline on the context of each request, see
+	// Request.Context. Clients receive a timeout error when handlers
+	// return without a reply after the deadline (0 to disable).
+	CommandTimeout time.Duration
+
+	// MaxBulkLen limits the length of a single argument, like redis'
+	// proto-max-bulk-len. Default: 512MB
+	MaxBulkLen int64
+
+	// MaxArgs limits the number of arguments of a single request,
+	// including the command name. Default: 1M
+	MaxArgs int
+
+	// MaxInlineSize limits the length of inline requests and of request
+	// header lines. Default: 64KB
+	MaxInlineSize int
+
+	// MaxClients limits the number of connected clients. Further
+	// connections are rejected with an error (0 to disable).
+	MaxClients int
+
+	// MaxClientsPerIP limits the number of TCP connections from the same
+	// remote IP (0 to disable).
+	MaxClientsPerIP int
+
+	// Admit is an optional hook, invoked for every accepted connection
+	// before the client limits are checked, e.g. to implement allow or
+	// deny lists. Connections are rejected with the returned error.
+	Admit func(conn net.Conn) error
+
+	// OutputBufferLimits limit the reply and pushed data buffered for
+	// each client, like redis' client-output-buffer-limit. Clients
+	// exceeding their limit are disconnected. Client classes without a
+	// limit use the defaults: like redis, no limit for normal clients,
+	// 256MB/64MB/1m for replicas and 32MB/8MB/1m for subscribed and
+	// monitoring clients.
+	OutputBufferLimits OutputBufferLimits
+
+	// DisableOutputBufferLimits disables output buffer limits for all
+	// client classes, including the defaults.
+	DisableOutputBufferLimits bool
+
+	// OnConnect is an optional callback, invoked once a client connected,
+	// e.g. to initialise Client.Ctx. Connections are rejected with the
+	// returned error. Clients are only listed, e.g. by CLIENT LIST, once
+	// accepted.
+	OnConnect func(client *Client) error
+
+	// OnDisconnect is an optional callback, invoked with the cause once a
+	// client disconnects. It is only invoked for clients accepted by
+	// OnConnect.
+	OnDisconnect func(client *Client, reason DisconnectReason)
+
+	// OnCommand is an optional callback, invoked after each command with
+	// the time spent in the handler and the outcome, i.e. the error
+	// returned by the handler. The request must not be retained.
+	OnCommand func(req *Request, elapsed time.Duration, err error)
+
+	// OnPanic is an optional callback, invoked when a handler panics. It
+	// receives the request, the recovered value and the stack trace, which
+	// are never sent to the client.
+	OnPanic func(req *Request, recovered interface{}, stack []byte)
+}
+
+// OutputBufferLimits configure output buffer limits per client class.
+// Subscribed and monitoring clients use the PubSub limit, clients
+// flagged via Client.SetReplica the Replica limit.
+type OutputBufferLimits struct {
+	Normal, Replica, PubSub OutputBufferLimit
+}
+
+// OutputBufferLimit limits the output buffered for a client. Clients
+// are disconnected as soon as the output exceeds the Hard limit, or when
+// it stays above the Soft limit for longer than SoftDuration. Zero values
+// disable the respective limit, a zero OutputBufferLimit applies the
+// default limit of the client class. A negative Hard limit disables the
+// limit of the client class altogether.
+type OutputBufferLimit struct {
+	Hard, Soft   int64
+	SoftDuration time.Duration
+}
+
+var defaultOutputBufferLimits = OutputBufferLimits{
+	Replica: OutputBufferLimit{Hard: 256 << 20, Soft: 64 << 20, SoftDuration: time.Minute},
+	PubSub:  OutputBufferLimit{Hard: 32 << 20, Soft: 8 << 20, SoftDuration: time.Minute},
+}
+
+// Returns the limit, or def if unset. Negative hard limits disable the
+// limit
+func (l OutputBufferLimit) orDefault(def OutputBufferLimit) OutputBufferLimit {
+	if l == (OutputBufferLimit{}) {
+		return def
+	} else if l.Hard < 0 {
+		return OutputBufferLimit{}
+	}
+	return l
 }
 
 // Default configuration is used when nil is passed to NewServer
 var DefaultConfig = &Config{
 	Addr: "0.0.0.0:9736",
 }
+
+// Returns the idle timeout
+func (c *Config) idleTimeout() time.Duration {
+	if c.IdleTimeout > 0 {
+		return c.IdleTimeout
+	}
+	return c.Timeout
+}
+
+// Returns the output buffer limits, applying defaults per client class.
+// Returns nil if limits are disabled
+func (c *Config) outputBufferLimits() *OutputBufferLimits {
+	if c.DisableOutputBufferLimits {
+		return nil
+	}
+
+	limits := c.OutputBufferLimits
+	limits.Normal = limits.Normal.orDefault(defaultOutputBufferLimits.Normal)
+	limits.Replica = limits.Replica.orDefault(defaultOutputBufferLimits.Replica)
+	limits.PubSub = limits.PubSub.orDefault(defaultOutputBufferLimits.PubSub)
+	return &limits
+}
+
+// Returns the protocol limits, applying defaults
+func (c *Config) requestLimits() requestLimits {
+	limits := defaultRequestLimits
+	if c.MaxBulkLen > 0 {
+		limits.bulkLen = c.MaxBulkLen
+	}
+	if c.MaxArgs > 0 {
+		limits.args = c.MaxArgs
+	}
+	if c.MaxInlineSize > 0 {
+		limits.inline = c.MaxInlineSize
+	}
+	return limits
+}
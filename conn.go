@@ -0,0 +1,148 @@
+package redeo
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// A time in the past, used to abort pending reads
+var aLongTimeAgo = time.Unix(1, 0)
+
+// Maximum size of a single write, when a write timeout is set
+const maxTimedWrite = 64 * 1024
+
+// connReader wraps the client connection, applying the read timeout
+// while a request is read. While a handler is served, it can read from
+// the connection in the background to detect disconnects.
+type connReader struct {
+	// number of bytes read, accessed atomically
+	n int64
+
+	conn    net.Conn
+	cancel  func()
+	timeout time.Duration
+
+	// reading is set while a request is read
+	reading     bool
+	hasDeadline bool
+
+	// enabled while no further input is buffered
+	enabled bool
+	done    chan struct{}
+
+	// set while a background read is aborted, accessed atomically
+	aborting int32
+
+	hasByte bool
+	byteBuf [1]byte
+}
+
+// Read implements io.Reader
+func (cr *connReader) Read(p []byte) (int, error) {
+	if cr.hasByte && len(p) != 0 {
+		p[0] = cr.byteBuf[0]
+		cr.hasByte = false
+		atomic.AddInt64(&cr.n, 1)
+		return 1, nil
+	}
+
+	if cr.reading && cr.timeout > 0 {
+		_ = cr.conn.SetReadDeadline(time.Now().Add(cr.timeout))
+		cr.hasDeadline = true
+	} else if cr.hasDeadline {
+		_ = cr.conn.SetReadDeadline(time.Time{})
+		cr.hasDeadline = false
+	}
+
+	n, err := cr.conn.Read(p)
+	atomic.AddInt64(&cr.n, int64(n))
+	return n, err
+}
+
+// startBackgroundRead starts watching the connection, cancelling the
+// client context when it is closed by the peer
+func (cr *connReader) startBackgroundRead() {
+	if !cr.enabled || cr.done != nil || cr.hasByte {
+		return
+	}
+
+	// Clear the read timeout, handlers may run for longer
+	if cr.hasDeadline {
+		_ = cr.conn.SetReadDeadline(time.Time{})
+		cr.hasDeadline = false
+	}
+
+	done := make(chan struct{})
+	cr.done = done
+	go func() {
+		defer close(done)
+
+		n, err := cr.conn.Read(cr.byteBuf[:])
+		if n == 1 {
+			cr.hasByte = true
+		}
+		if err != nil && !(isTimeout(err) && atomic.LoadInt32(&cr.aborting) != 0) {
+			cr.cancel()
+		}
+	}()
+}
+
+// abortPendingRead stops watching the connection
+func (cr *connReader) abortPendingRead() {
+	cr.enabled = false
+	if cr.done == nil {
+		return
+	}
+
+	atomic.StoreInt32(&cr.aborting, 1)
+	_ = cr.conn.SetReadDeadline(aLongTimeAgo)
+	<-cr.done
+	atomic.StoreInt32(&cr.aborting, 0)
+	_ = cr.conn.SetReadDeadline(time.Time{})
+	cr.hasDeadline = false
+	cr.done = nil
+}
+
+// connWriter wraps the client connection, applying the write timeout
+// to every write. Large writes are split into chunks so the timeout
+// limits stalls rather than the total transfer time.
+type connWriter struct {
+	// number of bytes written, accessed atomically
+	n int64
+
+	conn    net.Conn
+	timeout time.Duration
+}
+
+// Write implements io.Writer
+func (cw *connWriter) Write(p []byte) (n int, err error) {
+	if cw.timeout <= 0 {
+		n, err = cw.conn.Write(p)
+		atomic.AddInt64(&cw.n, int64(n))
+		return n, err
+	}
+
+	for len(p) != 0 {
+		chunk := p
+		if len(chunk) > maxTimedWrite {
+			chunk = chunk[:maxTimedWrite]
+		}
+
+		_ = cw.conn.SetWriteDeadline(time.Now().Add(cw.timeout))
+		m, err := cw.conn.Write(chunk)
+		atomic.AddInt64(&cw.n, int64(m))
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// Reports if err is a network timeout
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
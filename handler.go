@@ -13,3 +13,17 @@ type HandlerFunc func(out *Responder, req *Request) error
 func (f HandlerFunc) ServeClient(out *Responder, req *Request) error {
 	return f(out, req)
 }
+
+// Middleware wraps a handler to run code before and/or after it. A
+// middleware may short-circuit the chain by writing a reply (or returning
+// an error) without calling the next handler.
+type Middleware func(next Handler) Handler
+
+// Chain wraps a handler with middleware. The first middleware is the
+// outermost one and runs first.
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
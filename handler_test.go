@@ -0,0 +1,34 @@
+package redeo
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chain", func() {
+
+	var tag = func(s string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(out *Responder, req *Request) error {
+				req.Args = append(req.Args, s)
+				return next.ServeClient(out, req)
+			})
+		}
+	}
+
+	It("should apply middleware in order", func() {
+		handler := Chain(HandlerFunc(func(out *Responder, req *Request) error {
+			out.WriteStringBulk(req.Args)
+			return nil
+		}), tag("a"), tag("b"))
+
+		w := &bytes.Buffer{}
+		res := NewResponder(w)
+		Expect(handler.ServeClient(res, &Request{Name: "x"})).To(Succeed())
+		Expect(res.Flush()).To(Succeed())
+		Expect(w.String()).To(Equal("*2\r\n$1\r\na\r\n$1\r\nb\r\n"))
+	})
+
+})
@@ -0,0 +1,68 @@
+package redeo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// serveHello implements the HELLO [protover [AUTH username password]]
+// handshake, switching the client to the requested protocol version
+func (srv *Server) serveHello(out *Responder, req *Request) error {
+	proto := out.proto
+	if len(req.Args) > 0 {
+		n, err := strconv.Atoi(req.Args[0])
+		if err != nil {
+			return ClientError("Protocol version is not an integer or out of range")
+		}
+		if n != RESP2 && n != RESP3 {
+			out.WriteErrorString("NOPROTO unsupported protocol version")
+			return nil
+		}
+		proto = n
+	}
+
+	var username, password string
+	var auth bool
+	for i := 1; i < len(req.Args); i++ {
+		switch opt := strings.ToLower(req.Args[i]); {
+		case opt == "auth" && i+2 < len(req.Args):
+			username, password, auth = req.Args[i+1], req.Args[i+2], true
+			i += 2
+		default:
+			return ClientError("Syntax error in HELLO option '" + req.Args[i] + "'")
+		}
+	}
+
+	if client := req.client; client != nil && srv.auth != nil {
+		if auth && !srv.authenticate(client, username, password) {
+			out.WriteErrorString(msgWrongPass)
+			return nil
+		}
+		if client.User() == "" {
+			out.WriteErrorString("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+			return nil
+		}
+	}
+
+	var id uint64
+	if client := req.client; client != nil {
+		client.setProtocol(proto)
+		id = client.id
+	}
+	out.proto = proto
+
+	out.WriteMapLen(6)
+	out.WriteString("server")
+	out.WriteString("redeo")
+	out.WriteString("proto")
+	out.WriteInt(proto)
+	out.WriteString("id")
+	out.WriteInt(int(id))
+	out.WriteString("mode")
+	out.WriteString("standalone")
+	out.WriteString("role")
+	out.WriteString("master")
+	out.WriteString("modules")
+	out.WriteBulkLen(0)
+	return nil
+}
@@ -18,18 +18,34 @@ type ServerInfo struct {
 	pid       int
 
 	clients     *clients
+	blocking    *blocking
 	connections *info.Counter
 	commands    *info.Counter
+	panics      *info.Counter
+	protoErrors *info.Counter
+	pipelines   *info.Counter
+	pipelined   *info.Counter
+	timeouts    *info.Counter
+	overflows   *info.Counter
+	rejected    *info.Counter
 }
 
 // newServerInfo creates a new server info container
-func newServerInfo(config *Config, clients *clients) *ServerInfo {
+func newServerInfo(config *Config, clients *clients, blocking *blocking) *ServerInfo {
 	info := &ServerInfo{
 		registry:    info.New(),
 		startTime:   time.Now(),
 		connections: info.NewCounter(),
 		commands:    info.NewCounter(),
+		panics:      info.NewCounter(),
+		protoErrors: info.NewCounter(),
+		pipelines:   info.NewCounter(),
+		pipelined:   info.NewCounter(),
+		timeouts:    info.NewCounter(),
+		overflows:   info.NewCounter(),
+		rejected:    info.NewCounter(),
 		clients:     clients,
+		blocking:    blocking,
 	}
 	return info.withDefaults(config)
 }
@@ -45,6 +61,9 @@ func (i *ServerInfo) String() string { return i.registry.String() }
 // ClientsLen returns the number of connected clients
 func (i *ServerInfo) ClientsLen() int { return i.clients.Len() }
 
+// BlockedClientsLen returns the number of clients blocked by commands
+func (i *ServerInfo) BlockedClientsLen() int { return i.blocking.Len() }
+
 // Clients generates a slice of connected clients
 func (i *ServerInfo) Clients() []*Client { return i.clients.All() }
 
@@ -65,6 +84,38 @@ func (i *ServerInfo) TotalConnections() int64 { return i.connections.Value() }
 // of the server.
 func (i *ServerInfo) TotalCommands() int64 { return i.commands.Value() }
 
+// TotalPanics returns the total number of handler panics recovered since the
+// start of the server.
+func (i *ServerInfo) TotalPanics() int64 { return i.panics.Value() }
+
+// TotalProtocolErrors returns the total number of clients disconnected
+// because of malformed requests since the start of the server.
+func (i *ServerInfo) TotalProtocolErrors() int64 { return i.protoErrors.Value() }
+
+// TotalTimeouts returns the total number of connections closed because
+// of idle, read or write timeouts since the start of the server.
+func (i *ServerInfo) TotalTimeouts() int64 { return i.timeouts.Value() }
+
+// TotalOutputLimitDisconnections returns the total number of clients
+// disconnected because of exceeded output buffer limits since the start
+// of the server.
+func (i *ServerInfo) TotalOutputLimitDisconnections() int64 { return i.overflows.Value() }
+
+// TotalRejectedConnections returns the total number of connections
+// rejected by the admission hook or client limits since the start of
+// the server.
+func (i *ServerInfo) TotalRejectedConnections() int64 { return i.rejected.Value() }
+
+// AvgPipelineDepth returns the average number of requests served before
+// replies are flushed to the client.
+func (i *ServerInfo) AvgPipelineDepth() float64 {
+	n := i.pipelines.Value()
+	if n == 0 {
+		return 0
+	}
+	return float64(i.pipelined.Value()) / float64(n)
+}
+
 // ------------------------------------------------------------------------
 
 // Apply default info
@@ -88,10 +139,21 @@ func (i *ServerInfo) withDefaults(config *Config) *ServerInfo {
 	clients.Register("connected_clients", info.Callback(func() string {
 		return strconv.Itoa(i.ClientsLen())
 	}))
+	clients.Register("blocked_clients", info.Callback(func() string {
+		return strconv.Itoa(i.BlockedClientsLen())
+	}))
 
 	stats := i.Section("Stats")
 	stats.Register("total_connections_received", i.connections)
 	stats.Register("total_commands_processed", i.commands)
+	stats.Register("rejected_connections", i.rejected)
+	stats.Register("total_panics_recovered", i.panics)
+	stats.Register("total_protocol_errors", i.protoErrors)
+	stats.Register("total_connections_timed_out", i.timeouts)
+	stats.Register("client_output_buffer_limit_disconnections", i.overflows)
+	stats.Register("avg_pipeline_depth", info.Callback(func() string {
+		return strconv.FormatFloat(i.AvgPipelineDepth(), 'f', 2, 64)
+	}))
 
 	return i
 }
@@ -101,3 +163,24 @@ func (i *ServerInfo) onConnect() { i.connections.Inc(1) }
 
 // Callback to track processed command
 func (i *ServerInfo) onCommand() { i.commands.Inc(1) }
+
+// Callback to track rejected connections
+func (i *ServerInfo) onReject() { i.rejected.Inc(1) }
+
+// Callback to track recovered panics
+func (i *ServerInfo) onPanic() { i.panics.Inc(1) }
+
+// Callback to track protocol errors
+func (i *ServerInfo) onProtocolError() { i.protoErrors.Inc(1) }
+
+// Callback to track connections closed by timeouts
+func (i *ServerInfo) onTimeout(n int) { i.timeouts.Inc(int64(n)) }
+
+// Callback to track clients exceeding the output buffer limit
+func (i *ServerInfo) onOutputLimit() { i.overflows.Inc(1) }
+
+// Callback to track the number of requests served in a single flush
+func (i *ServerInfo) onPipeline(depth int) {
+	i.pipelines.Inc(1)
+	i.pipelined.Inc(int64(depth))
+}
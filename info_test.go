@@ -22,7 +22,7 @@ var _ = Describe("ServerInfo", func() {
 		subject = newServerInfo(&Config{
 			Addr:   "127.0.0.1:9736",
 			Socket: "/tmp/redeo.sock",
-		}, clients)
+		}, clients, newBlocking())
 		for i := 0; i < 5; i++ {
 			subject.onConnect()
 		}
@@ -39,7 +39,7 @@ var _ = Describe("ServerInfo", func() {
 		Expect(str).To(MatchRegexp(`uptime_in_seconds:\d+\n`))
 		Expect(str).To(MatchRegexp(`uptime_in_days:\d+\n`))
 
-		Expect(str).To(ContainSubstring("# Clients\nconnected_clients:3\n"))
+		Expect(str).To(ContainSubstring("# Clients\nconnected_clients:3\nblocked_clients:0\n"))
 		Expect(str).To(ContainSubstring("# Stats\ntotal_connections_received:5\ntotal_commands_processed:12\n"))
 	})
 
@@ -49,9 +49,9 @@ var _ = Describe("ServerInfo", func() {
 
 	It("should generate client string", func() {
 		str := subject.ClientsString()
-		Expect(str).To(MatchRegexp(`id=\d+ addr=1\.2\.3\.4\:10001 age=\d+ idle=\d+ cmd=get`))
-		Expect(str).To(MatchRegexp(`id=\d+ addr=1\.2\.3\.4\:10002 age=\d+ idle=\d+ cmd=set`))
-		Expect(str).To(MatchRegexp(`id=\d+ addr=1\.2\.3\.4\:10004 age=\d+ idle=\d+ cmd=info`))
+		Expect(str).To(MatchRegexp(`id=\d+ addr=1\.2\.3\.4\:10001 laddr=127\.0\.0\.1\:9736 fd=-1 name= age=\d+ idle=\d+ flags=N db=0 sub=0 psub=0 multi=-1 qbuf=0 obl=0 oll=0 omem=0 cmd=get user=`))
+		Expect(str).To(MatchRegexp(`id=\d+ addr=1\.2\.3\.4\:10002 laddr=127\.0\.0\.1\:9736 fd=-1 name= age=\d+ idle=\d+ flags=N db=0 sub=0 psub=0 multi=-1 qbuf=0 obl=0 oll=0 omem=0 cmd=set user=`))
+		Expect(str).To(MatchRegexp(`id=\d+ addr=1\.2\.3\.4\:10004 laddr=127\.0\.0\.1\:9736 fd=-1 name= age=\d+ idle=\d+ flags=N db=0 sub=0 psub=0 multi=-1 qbuf=0 obl=0 oll=0 omem=0 cmd=info user=`))
 	})
 
 })
@@ -0,0 +1,109 @@
+package redeo
+
+var errUnbalancedQuotes = ProtocolError("unbalanced quotes in request")
+
+// splitInline tokenizes an inline request line, exactly like redis'
+// sdssplitargs. Arguments are separated by whitespace and may be quoted.
+// Double quoted arguments support \n, \r, \t, \b, \a and \xHH escapes,
+// single quoted arguments support \' only. Arguments are appended to
+// the buffer.
+func (r *requestReader) splitInline(line []byte) error {
+	for i := 0; ; {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return nil
+		}
+
+		start := len(r.buf)
+		inq, insq := false, false
+		for done := false; !done; i++ {
+			if i == len(line) {
+				if inq || insq {
+					return errUnbalancedQuotes
+				}
+				break
+			}
+
+			c := line[i]
+			switch {
+			case inq:
+				if c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]) {
+					r.buf = append(r.buf, unhex(line[i+2])<<4|unhex(line[i+3]))
+					i += 3
+				} else if c == '\\' && i+1 < len(line) {
+					i++
+					switch c = line[i]; c {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					case 'b':
+						c = '\b'
+					case 'a':
+						c = '\a'
+					}
+					r.buf = append(r.buf, c)
+				} else if c == '"' {
+					// closing quote must be followed by a space or nothing
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return errUnbalancedQuotes
+					}
+					done = true
+				} else {
+					r.buf = append(r.buf, c)
+				}
+			case insq:
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					r.buf = append(r.buf, '\'')
+					i++
+				} else if c == '\'' {
+					// closing quote must be followed by a space or nothing
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return errUnbalancedQuotes
+					}
+					done = true
+				} else {
+					r.buf = append(r.buf, c)
+				}
+			default:
+				switch c {
+				case ' ', '\n', '\r', '\t', 0:
+					done = true
+				case '"':
+					inq = true
+				case '\'':
+					insq = true
+				default:
+					r.buf = append(r.buf, c)
+				}
+			}
+		}
+		r.offs = append(r.offs, start, len(r.buf))
+	}
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
@@ -0,0 +1,61 @@
+package redeo
+
+import (
+	"bufio"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("splitInline", func() {
+
+	split := func(line string) ([]string, error) {
+		r := &requestReader{}
+		if err := r.splitInline([]byte(line)); err != nil {
+			return nil, err
+		}
+
+		args := make([]string, 0, len(r.offs)/2)
+		for i := 0; i < len(r.offs); i += 2 {
+			args = append(args, string(r.buf[r.offs[i]:r.offs[i+1]]))
+		}
+		return args, nil
+	}
+
+	It("should split by whitespace", func() {
+		Expect(split("")).To(BeEmpty())
+		Expect(split(" \t ")).To(BeEmpty())
+		Expect(split("set foo bar")).To(Equal([]string{"set", "foo", "bar"}))
+		Expect(split("  set \t foo   bar  ")).To(Equal([]string{"set", "foo", "bar"}))
+	})
+
+	It("should support double quotes", func() {
+		Expect(split(`set "foo bar" ""`)).To(Equal([]string{"set", "foo bar", ""}))
+		Expect(split(`set k "a\nb\r\t\"\\\b\a\q"`)).To(Equal([]string{"set", "k", "a\nb\r\t\"\\\b\aq"}))
+		Expect(split(`set k "\x41\x7a\xff\xzz"`)).To(Equal([]string{"set", "k", "Az\xffxzz"}))
+		Expect(split(`set k"ey" v`)).To(Equal([]string{"set", "key", "v"}))
+	})
+
+	It("should support single quotes", func() {
+		Expect(split(`set 'foo bar' ''`)).To(Equal([]string{"set", "foo bar", ""}))
+		Expect(split(`set k 'it\'s \n'`)).To(Equal([]string{"set", "k", `it's \n`}))
+	})
+
+	It("should reject unbalanced quotes", func() {
+		for _, line := range []string{`set "foo`, `set 'foo`, `set "foo"bar`, `set 'foo'bar`, `set "foo\"`} {
+			_, err := split(line)
+			Expect(err).To(Equal(errUnbalancedQuotes), line)
+			Expect(err.Error()).To(Equal("Protocol error: unbalanced quotes in request"))
+		}
+	})
+
+	It("should skip blank inline requests", func() {
+		rd := requestReader{rd: bufio.NewReader(strings.NewReader("\r\n  \r\nPING\n")), limits: defaultRequestLimits}
+		req, err := rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Name).To(Equal("ping"))
+		Expect(req.NumArgs()).To(Equal(0))
+	})
+
+})
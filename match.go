@@ -0,0 +1,80 @@
+package redeo
+
+// matchGlob reports whether s matches the glob-style pattern, following
+// the redis rules: '*', '?', '[abc]', '[^abc]', '[a-z]' and '\' escapes.
+// Only the most recent '*' is backtracked, so matching takes at most
+// O(len(pattern) * len(s)) steps, even for patterns like "*a*a*a*b".
+func matchGlob(pattern, s string) bool {
+	p, i := 0, 0
+	star, next := -1, 0
+
+	for i < len(s) || p < len(pattern) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				star, next = p, i
+				p++
+				continue
+			}
+			if i < len(s) {
+				if n, ok := matchChar(pattern[p:], s[i]); ok {
+					p += n
+					i++
+					continue
+				}
+			}
+		}
+
+		// Let the last '*' consume one more character
+		if star < 0 || next >= len(s) {
+			return false
+		}
+		next++
+		p, i = star+1, next
+	}
+	return true
+}
+
+// matchChar matches c against the first element of the pattern, returns
+// the length of the element
+func matchChar(pattern string, c byte) (int, bool) {
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '[':
+		j := 1
+		not := j < len(pattern) && pattern[j] == '^'
+		if not {
+			j++
+		}
+
+		match := false
+		for j < len(pattern) && pattern[j] != ']' {
+			switch {
+			case pattern[j] == '\\' && j+1 < len(pattern):
+				j++
+				match = match || pattern[j] == c
+			case j+2 < len(pattern) && pattern[j+1] == '-':
+				lo, hi := pattern[j], pattern[j+2]
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				match = match || (c >= lo && c <= hi)
+				j += 2
+			default:
+				match = match || pattern[j] == c
+			}
+			j++
+		}
+
+		// Skip the closing bracket, unterminated classes end the pattern
+		if j < len(pattern) {
+			j++
+		}
+		return j, match != not
+	case '\\':
+		if len(pattern) > 1 {
+			return 2, pattern[1] == c
+		}
+	}
+	return 1, pattern[0] == c
+}
@@ -0,0 +1,58 @@
+package redeo
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("matchGlob", func() {
+
+	It("should match patterns", func() {
+		for _, c := range []struct {
+			p, s string
+			m    bool
+		}{
+			{"news.*", "news.tech", true},
+			{"news.*", "news.", true},
+			{"news.*", "sport.tech", false},
+			{"*", "", true},
+			{"**a", "bba", true},
+			{"h?llo", "hello", true},
+			{"h?llo", "hllo", false},
+			{"h[ae]llo", "hallo", true},
+			{"h[ae]llo", "hillo", false},
+			{"h[^e]llo", "hallo", true},
+			{"h[^e]llo", "hello", false},
+			{"h[a-b]llo", "hbllo", true},
+			{"h[b-a]llo", "hallo", true},
+			{"h[a-b]llo", "hcllo", false},
+			{`h\*llo`, "h*llo", true},
+			{`h\*llo`, "hello", false},
+			{`h[\]]llo`, "h]llo", true},
+			{"exact", "exact", true},
+			{"exact", "exactly", false},
+			{"*a*b", "xaxxb", true},
+			{"a*b*c", "abbbc", true},
+			{"a*b*c", "abbb", false},
+			{"*.[ch]", "main.c", true},
+			{"h[ab", "ha", true},
+			{"h[ab", "hab", false},
+		} {
+			Expect(matchGlob(c.p, c.s)).To(Equal(c.m), c.p+" ~ "+c.s)
+		}
+	})
+
+	It("should not backtrack excessively", func() {
+		pattern := strings.Repeat("*a", 32) + "*b"
+		subject := strings.Repeat("a", 4096)
+
+		start := time.Now()
+		Expect(matchGlob(pattern, subject)).To(BeFalse())
+		Expect(matchGlob(pattern, subject+"b")).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+})
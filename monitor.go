@@ -0,0 +1,174 @@
+package redeo
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Commands which monitoring clients may execute
+var monitorModeCommands = map[string]bool{
+	"quit":  true,
+	"reset": true,
+}
+
+// monitors feeds executed commands to MONITOR clients
+type monitors struct {
+	m     map[*Client]struct{}
+	n     int32
+	mutex sync.RWMutex
+}
+
+func newMonitors() *monitors {
+	return &monitors{m: make(map[*Client]struct{})}
+}
+
+// Add registers a monitor
+func (m *monitors) Add(client *Client) {
+	m.mutex.Lock()
+	m.m[client] = struct{}{}
+	atomic.StoreInt32(&m.n, int32(len(m.m)))
+	m.mutex.Unlock()
+
+	client.setMonitor(true)
+}
+
+// Remove unregisters a monitor
+func (m *monitors) Remove(client *Client) {
+	m.mutex.Lock()
+	delete(m.m, client)
+	atomic.StoreInt32(&m.n, int32(len(m.m)))
+	m.mutex.Unlock()
+}
+
+// Len returns the number of monitors
+func (m *monitors) Len() int {
+	return int(atomic.LoadInt32(&m.n))
+}
+
+// Feed writes a request to all monitors. Messages are queued, so slow
+// monitors are subject to output buffer limits rather than stalling the
+// server
+func (m *monitors) Feed(req *Request) {
+	if m.Len() == 0 {
+		return
+	}
+
+	m.mutex.RLock()
+	clients := make([]*Client, 0, len(m.m))
+	for client := range m.m {
+		clients = append(clients, client)
+	}
+	m.mutex.RUnlock()
+
+	line := monitorLine(time.Now(), req)
+	for _, client := range clients {
+		_ = client.push(line)
+	}
+}
+
+// monitorLine formats a request, e.g.:
+//
+//	+1700000000.123456 [0 127.0.0.1:52614] "set" "key" "value"
+func monitorLine(now time.Time, req *Request) []byte {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '+')
+	buf = strconv.AppendInt(buf, now.Unix(), 10)
+	buf = append(buf, '.')
+	micros := strconv.Itoa(now.Nanosecond() / 1000)
+	for i := len(micros); i < 6; i++ {
+		buf = append(buf, '0')
+	}
+	buf = append(buf, micros...)
+
+	buf = append(buf, " ["...)
+	if client := req.client; client != nil {
+		buf = strconv.AppendInt(buf, int64(client.DB()), 10)
+		buf = append(buf, ' ')
+		buf = append(buf, monitorAddr(client)...)
+	} else {
+		buf = append(buf, "0 unknown"...)
+	}
+	buf = append(buf, ']')
+
+	// Show the command name as sent by the client
+	name := req.Name
+	if req.rawName != nil {
+		name = string(req.rawName)
+	}
+	buf = append(buf, ' ')
+	buf = appendRepr(buf, name)
+	for i, n := 0, req.NumArgs(); i < n; i++ {
+		buf = append(buf, ' ')
+		buf = appendRepr(buf, string(req.Arg(i)))
+	}
+	if req.stream != nil {
+		buf = append(buf, ' ')
+		buf = appendRepr(buf, "("+strconv.FormatInt(req.streamLen, 10)+" bytes)")
+	}
+	return append(buf, "\r\n"...)
+}
+
+// Returns the client address, as displayed by MONITOR
+func monitorAddr(client *Client) string {
+	addr := client.RemoteAddr()
+	if addr.Network() == "unix" {
+		return "unix:" + client.LocalAddr().String()
+	}
+	return addr.String()
+}
+
+// appendRepr appends a quoted, escaped string, like redis' sdscatrepr
+func appendRepr(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			buf = append(buf, '\\', c)
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\a':
+			buf = append(buf, '\\', 'a')
+		case '\b':
+			buf = append(buf, '\\', 'b')
+		default:
+			if c < ' ' || c > '~' {
+				buf = append(buf, '\\', 'x', hex[c>>4], hex[c&0xf])
+			} else {
+				buf = append(buf, c)
+			}
+		}
+	}
+	return append(buf, '"')
+}
+
+// ------------------------------------------------------------------------
+
+// Feeds a request to monitors, unless the command is excluded. Admin
+// commands and commands flagged with FlagSkipMonitor, e.g. AUTH, are
+// never shown
+func (srv *Server) feedMonitors(req *Request, cmd *command) {
+	if cmd.info.Flags&(FlagAdmin|FlagSkipMonitor) != 0 {
+		return
+	}
+	srv.monitors.Feed(req)
+}
+
+func (srv *Server) serveMonitor(out *Responder, req *Request) error {
+	if req.client == nil {
+		return errNoClient
+	}
+	if req.inTx {
+		return ClientError("MONITOR isn't allowed in a transaction")
+	}
+
+	srv.monitors.Add(req.client)
+	return nil
+}
@@ -0,0 +1,126 @@
+package redeo
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Monitor", func() {
+	var subject *Server
+	var conn *mockConn
+	var monitor *Client
+
+	BeforeEach(func() {
+		subject = NewServer(nil)
+		subject.HandleFunc("set", func(out *Responder, _ *Request) error {
+			return nil
+		})
+
+		conn = &mockConn{Port: 10001}
+		monitor = NewClient(conn)
+	})
+
+	It("should format requests", func() {
+		client := NewClient(&mockConn{Port: 10002})
+		client.SetDB(3)
+
+		line := monitorLine(time.Unix(1700000000, 12345000), &Request{Name: "set", Args: []string{"key", "a \"b\"\r\n\\\x01\xff"}, client: client})
+		Expect(string(line)).To(Equal(`+1700000000.012345 [3 1.2.3.4:10002] "set" "key" "a \"b\"\r\n\\\x01\xff"` + "\r\n"))
+
+		line = monitorLine(time.Unix(1700000000, 0), &Request{Name: "ping"})
+		Expect(string(line)).To(Equal(`+1700000000.000000 [0 unknown] "ping"` + "\r\n"))
+	})
+
+	It("should show command names as sent", func() {
+		rd := newRequestReader(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nSeT\r\n$1\r\nk\r\n")))
+		defer rd.release()
+
+		req, err := rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Name).To(Equal("set"))
+		Expect(string(monitorLine(time.Unix(1700000000, 0), req))).To(HaveSuffix(`] "SeT" "k"` + "\r\n"))
+
+		cp, err := req.detach()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(monitorLine(time.Unix(1700000000, 0), cp))).To(HaveSuffix(`] "SeT" "k"` + "\r\n"))
+	})
+
+	It("should feed commands", func() {
+		Expect(apply(subject, monitor, "monitor")).To(Equal("+OK\r\n"))
+		Expect(monitor.Flags()).To(Equal(ClientMonitor))
+		Expect(subject.monitors.Len()).To(Equal(1))
+
+		client := NewClient(&mockConn{Port: 10002})
+		Expect(apply(subject, client, "set", "key", "value")).To(Equal("+OK\r\n"))
+		Eventually(conn.String).Should(MatchRegexp(`^\+\d+\.\d{6} \[0 1\.2\.3\.4:10002\] "set" "key" "value"\r\n$`))
+
+		subject.monitors.Remove(monitor)
+		Expect(subject.monitors.Len()).To(Equal(0))
+	})
+
+	It("should exclude sensitive and admin commands", func() {
+		apply(subject, monitor, "monitor")
+
+		client := NewClient(&mockConn{Port: 10002})
+		apply(subject, client, "auth", "secret")
+		apply(subject, client, "hello", "3", "auth", "default", "secret")
+		apply(subject, client, "client", "list")
+		apply(subject, client, "unknown")
+		apply(subject, client, "set", "key", "value")
+		Eventually(conn.String).Should(ContainSubstring(`"set"`))
+		Expect(conn.String()).NotTo(ContainSubstring("secret"))
+		Expect(conn.String()).NotTo(ContainSubstring("client"))
+		Expect(conn.String()).NotTo(ContainSubstring("unknown"))
+	})
+
+	It("should only allow QUIT and RESET while monitoring", func() {
+		subject.HandleFunc("quit", func(out *Responder, req *Request) error {
+			out.WriteOK()
+			return nil
+		})
+		Expect(apply(subject, monitor, "monitor")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, monitor, "set", "key", "value")).To(Equal("-ERR Can't execute 'set': only QUIT / RESET are allowed in MONITOR mode\r\n"))
+		Expect(apply(subject, monitor, "monitor")).To(Equal("-ERR Can't execute 'monitor': only QUIT / RESET are allowed in MONITOR mode\r\n"))
+		Expect(apply(subject, monitor, "quit")).To(Equal("+OK\r\n"))
+		Eventually(conn.String).Should(ContainSubstring(`"quit"`))
+		Expect(conn.String()).NotTo(ContainSubstring(`"set"`))
+	})
+
+	It("should not be allowed in transactions", func() {
+		apply(subject, monitor, "multi")
+		Expect(apply(subject, monitor, "monitor")).To(Equal("+QUEUED\r\n"))
+		Expect(apply(subject, monitor, "exec")).To(Equal("*1\r\n-ERR MONITOR isn't allowed in a transaction\r\n"))
+	})
+
+	It("should disconnect slow monitors", func() {
+		subject = NewServer(&Config{OutputBufferLimits: OutputBufferLimits{
+			PubSub: OutputBufferLimit{Hard: 1024},
+		}})
+		subject.HandleFunc("set", func(out *Responder, _ *Request) error {
+			return nil
+		})
+
+		cn, sn := net.Pipe()
+		go subject.serveClient(NewClient(sn))
+		defer cn.Close()
+
+		rd := bufio.NewReader(cn)
+		go cn.Write([]byte("MONITOR\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("+OK\r\n"))
+		Eventually(subject.monitors.Len).Should(Equal(1))
+
+		client := NewClient(&mockConn{})
+		for i := 0; i < 50; i++ {
+			apply(subject, client, "set", "key", "a long enough value")
+		}
+		Eventually(subject.Info().ClientsLen).Should(Equal(0))
+		Expect(subject.monitors.Len()).To(Equal(0))
+		Expect(subject.Info().TotalOutputLimitDisconnections()).To(Equal(int64(1)))
+	})
+
+})
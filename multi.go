@@ -0,0 +1,224 @@
+package redeo
+
+import (
+	"io/ioutil"
+	"sync"
+)
+
+// Commands which are executed immediately within MULTI
+var txCommands = map[string]bool{
+	"exec":    true,
+	"discard": true,
+	"multi":   true,
+	"watch":   true,
+	"unwatch": true,
+}
+
+var binNullArray = []byte("*-1\r\n")
+
+// SetTxLock sets the lock held while EXEC runs the queued commands of
+// a transaction. By default, transactions are only executed in isolation
+// from other transactions. To isolate them from regular commands too,
+// handlers must hold the same lock while accessing shared data, unless
+// req.InTransaction() reports that EXEC is already holding it. Locking
+// it again from within a transaction would deadlock with non-reentrant
+// locks such as a sync.Mutex.
+// Not thread-safe, don't call from multiple goroutines
+func (srv *Server) SetTxLock(lock sync.Locker) {
+	srv.txLock = lock
+}
+
+// MarkDirty marks keys as modified, causing EXEC to abort the
+// transactions of all clients watching any of the keys. Handlers which
+// modify data should call MarkDirty to support WATCH.
+func (srv *Server) MarkDirty(keys ...string) { srv.watches.MarkDirty(keys...) }
+
+// ------------------------------------------------------------------------
+
+// transaction holds the commands queued after MULTI
+type transaction struct {
+	queue  []*Request
+	failed bool
+}
+
+// watches tracks the keys watched by clients
+type watches struct {
+	keys  map[string]map[*Client]struct{}
+	mutex sync.Mutex
+}
+
+func newWatches() *watches {
+	return &watches{keys: make(map[string]map[*Client]struct{})}
+}
+
+// Watch adds keys to the client's watch list
+func (w *watches) Watch(client *Client, keys ...string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, key := range keys {
+		clients, ok := w.keys[key]
+		if !ok {
+			clients = make(map[*Client]struct{})
+			w.keys[key] = clients
+		}
+		clients[client] = struct{}{}
+		client.watch(key)
+	}
+}
+
+// Unwatch clears the client's watch list and dirty state
+func (w *watches) Unwatch(client *Client) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, key := range client.unwatch() {
+		if clients, ok := w.keys[key]; ok {
+			delete(clients, client)
+			if len(clients) == 0 {
+				delete(w.keys, key)
+			}
+		}
+	}
+}
+
+// MarkDirty flags all clients watching keys
+func (w *watches) MarkDirty(keys ...string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, key := range keys {
+		for client := range w.keys[key] {
+			client.markDirty()
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+
+// Creates a copy of a request for deferred execution, which no longer
+// references connection buffers
+func (r *Request) detach() (*Request, error) {
+	cp := &Request{Name: r.Name, Ctx: r.Ctx, client: r.client}
+	if r.rawName != nil {
+		cp.rawName = append([]byte(nil), r.rawName...)
+	}
+
+	n := r.NumArgs()
+	cp.Args = make([]string, n, n+1)
+	for i := range cp.Args {
+		cp.Args[i] = string(r.Arg(i))
+	}
+
+	if r.stream != nil {
+		data, err := ioutil.ReadAll(r.stream)
+		if err != nil {
+			return nil, err
+		}
+		cp.Args = append(cp.Args, string(data))
+	}
+	return cp, nil
+}
+
+// responderWriter appends raw data to a responder
+type responderWriter struct{ *Responder }
+
+func (w responderWriter) Write(p []byte) (int, error) {
+	w.writeRaw(p)
+	return len(p), w.err
+}
+
+// ------------------------------------------------------------------------
+
+// Queues a command within MULTI
+func (srv *Server) queueCommand(out *Responder, req *Request) error {
+	cp, err := req.detach()
+	if err != nil {
+		return err
+	}
+
+	req.client.queueCommand(cp)
+	out.WriteInlineString("QUEUED")
+	return nil
+}
+
+func (srv *Server) serveMulti(out *Responder, req *Request) error {
+	if req.client == nil {
+		return errNoClient
+	}
+	if !req.client.beginMulti() {
+		return ClientError("MULTI calls can not be nested")
+	}
+	out.WriteOK()
+	return nil
+}
+
+func (srv *Server) serveExec(out *Responder, req *Request) error {
+	if req.client == nil {
+		return errNoClient
+	}
+
+	tx := req.client.endMulti()
+	if tx == nil {
+		return ClientError("EXEC without MULTI")
+	}
+	defer srv.watches.Unwatch(req.client)
+
+	if tx.failed {
+		out.WriteErrorString("EXECABORT Transaction discarded because of previous errors.")
+		return nil
+	}
+
+	srv.txLock.Lock()
+	defer srv.txLock.Unlock()
+
+	if req.client.isDirty() {
+		if out.proto < RESP3 {
+			out.writeRaw(binNullArray)
+		} else {
+			out.WriteNull()
+		}
+		return nil
+	}
+
+	out.WriteBulkLen(len(tx.queue))
+	for _, queued := range tx.queue {
+		queued.inTx = true
+		srv.apply(queued, responderWriter{out})
+	}
+	return nil
+}
+
+func (srv *Server) serveDiscard(out *Responder, req *Request) error {
+	if req.client == nil {
+		return errNoClient
+	}
+	if req.client.endMulti() == nil {
+		return ClientError("DISCARD without MULTI")
+	}
+
+	srv.watches.Unwatch(req.client)
+	out.WriteOK()
+	return nil
+}
+
+func (srv *Server) serveWatch(out *Responder, req *Request) error {
+	if req.client == nil {
+		return errNoClient
+	}
+	if req.client.inMulti() {
+		return ClientError("WATCH inside MULTI is not allowed")
+	}
+
+	srv.watches.Watch(req.client, req.Args...)
+	out.WriteOK()
+	return nil
+}
+
+func (srv *Server) serveUnwatch(out *Responder, req *Request) error {
+	if req.client != nil {
+		srv.watches.Unwatch(req.client)
+	}
+	out.WriteOK()
+	return nil
+}
@@ -0,0 +1,139 @@
+package redeo
+
+import (
+	"bytes"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transactions", func() {
+	var subject *Server
+	var client *Client
+	var data map[string]string
+
+	BeforeEach(func() {
+		data = make(map[string]string)
+		client = NewClient(&mockConn{})
+
+		subject = NewServer(nil)
+		subject.HandleCommand(CommandInfo{Name: "get", Arity: 2}, HandlerFunc(func(out *Responder, req *Request) error {
+			if val, ok := data[req.Args[0]]; ok {
+				out.WriteString(val)
+			} else {
+				out.WriteNil()
+			}
+			return nil
+		}))
+		subject.HandleCommand(CommandInfo{Name: "set", Arity: 3}, HandlerFunc(func(out *Responder, req *Request) error {
+			data[req.Args[0]] = req.Args[1]
+			subject.MarkDirty(req.Args[0])
+			out.WriteOK()
+			return nil
+		}))
+	})
+
+	It("should queue and execute commands", func() {
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "set", "k", "v")).To(Equal("+QUEUED\r\n"))
+		Expect(apply(subject, client, "get", "k")).To(Equal("+QUEUED\r\n"))
+		Expect(data).To(BeEmpty())
+
+		Expect(apply(subject, client, "exec")).To(Equal("*2\r\n+OK\r\n$1\r\nv\r\n"))
+		Expect(data).To(HaveKeyWithValue("k", "v"))
+		Expect(apply(subject, client, "exec")).To(Equal("-ERR EXEC without MULTI\r\n"))
+	})
+
+	It("should copy queued arguments", func() {
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+
+		buf := []byte("kv")
+		w := &bytes.Buffer{}
+		subject.apply(&Request{Name: "set", argv: [][]byte{buf[:1], buf[1:]}, client: client}, w)
+		Expect(w.String()).To(Equal("+QUEUED\r\n"))
+		copy(buf, "xx")
+
+		Expect(apply(subject, client, "exec")).To(Equal("*1\r\n+OK\r\n"))
+		Expect(data).To(HaveKeyWithValue("k", "v"))
+	})
+
+	It("should discard transactions", func() {
+		Expect(apply(subject, client, "discard")).To(Equal("-ERR DISCARD without MULTI\r\n"))
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "multi")).To(Equal("-ERR MULTI calls can not be nested\r\n"))
+		Expect(apply(subject, client, "set", "k", "v")).To(Equal("+QUEUED\r\n"))
+		Expect(apply(subject, client, "discard")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "get", "k")).To(Equal("$-1\r\n"))
+	})
+
+	It("should abort on queue-time errors", func() {
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "set", "k", "v")).To(Equal("+QUEUED\r\n"))
+		Expect(apply(subject, client, "set", "k")).To(Equal("-ERR wrong number of arguments for 'set' command\r\n"))
+		Expect(apply(subject, client, "unknown")).To(Equal("-ERR unknown command 'unknown'\r\n"))
+		Expect(apply(subject, client, "exec")).To(Equal("-EXECABORT Transaction discarded because of previous errors.\r\n"))
+		Expect(data).To(BeEmpty())
+	})
+
+	It("should abort when watched keys are modified", func() {
+		Expect(apply(subject, client, "watch", "k")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "watch", "x")).To(Equal("-ERR WATCH inside MULTI is not allowed\r\n"))
+		Expect(apply(subject, client, "get", "k")).To(Equal("+QUEUED\r\n"))
+
+		subject.MarkDirty("k")
+		Expect(apply(subject, client, "exec")).To(Equal("*-1\r\n"))
+
+		// watches are cleared after EXEC
+		subject.MarkDirty("k")
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "get", "k")).To(Equal("+QUEUED\r\n"))
+		Expect(apply(subject, client, "exec")).To(Equal("*1\r\n$-1\r\n"))
+	})
+
+	It("should unwatch keys", func() {
+		Expect(apply(subject, client, "watch", "k")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "unwatch")).To(Equal("+OK\r\n"))
+		Expect(subject.watches.keys).To(BeEmpty())
+
+		subject.MarkDirty("k")
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "exec")).To(Equal("*0\r\n"))
+	})
+
+	It("should execute under the transaction lock", func() {
+		var mu sync.Mutex
+		subject.SetTxLock(&mu)
+		subject.HandleFunc("locked", func(out *Responder, req *Request) error {
+			if !req.InTransaction() {
+				mu.Lock()
+				defer mu.Unlock()
+			}
+			out.WriteInt(1)
+			return nil
+		})
+
+		Expect(apply(subject, client, "locked")).To(Equal(":1\r\n"))
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "locked")).To(Equal("+QUEUED\r\n"))
+		Expect(apply(subject, client, "locked")).To(Equal("+QUEUED\r\n"))
+		Expect(apply(subject, client, "exec")).To(Equal("*2\r\n:1\r\n:1\r\n"))
+		Expect(apply(subject, client, "locked")).To(Equal(":1\r\n"))
+	})
+
+	It("should not block within transactions", func() {
+		subject.HandleFunc("blpop", func(out *Responder, req *Request) error {
+			w := subject.Block(req, 0, req.Args...)
+			defer w.Close()
+
+			_, err := w.Wait()
+			return err
+		})
+
+		Expect(apply(subject, client, "multi")).To(Equal("+OK\r\n"))
+		Expect(apply(subject, client, "blpop", "k")).To(Equal("+QUEUED\r\n"))
+		Expect(apply(subject, client, "exec")).To(Equal("*1\r\n$-1\r\n"))
+	})
+
+})
@@ -0,0 +1,359 @@
+package redeo
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// Commands which can be executed by RESP2 clients in subscribed mode
+var subscribedModeCommands = map[string]bool{
+	"subscribe":    true,
+	"psubscribe":   true,
+	"ssubscribe":   true,
+	"unsubscribe":  true,
+	"punsubscribe": true,
+	"sunsubscribe": true,
+	"ping":         true,
+	"quit":         true,
+	"reset":        true,
+}
+
+type subscribers map[*Client]struct{}
+
+type pubsub struct {
+	channels map[string]subscribers
+	patterns map[string]subscribers
+	mutex    sync.RWMutex
+}
+
+func newPubSub() *pubsub {
+	return &pubsub{
+		channels: make(map[string]subscribers),
+		patterns: make(map[string]subscribers),
+	}
+}
+
+// Subscribe subscribes a client to a channel, returns the number of
+// client subscriptions
+func (p *pubsub) Subscribe(client *Client, channel string) int {
+	return p.add(p.channels, &client.channels, client, channel)
+}
+
+// PSubscribe subscribes a client to a pattern, returns the number of
+// client subscriptions
+func (p *pubsub) PSubscribe(client *Client, pattern string) int {
+	return p.add(p.patterns, &client.patterns, client, pattern)
+}
+
+// Unsubscribe unsubscribes a client from a channel, returns the number of
+// remaining client subscriptions
+func (p *pubsub) Unsubscribe(client *Client, channel string) int {
+	return p.remove(p.channels, &client.channels, client, channel)
+}
+
+// PUnsubscribe unsubscribes a client from a pattern, returns the number of
+// remaining client subscriptions
+func (p *pubsub) PUnsubscribe(client *Client, pattern string) int {
+	return p.remove(p.patterns, &client.patterns, client, pattern)
+}
+
+// UnsubscribeAll removes all client subscriptions
+func (p *pubsub) UnsubscribeAll(client *Client) {
+	for _, channel := range p.Subscriptions(client, false) {
+		p.Unsubscribe(client, channel)
+	}
+	for _, pattern := range p.Subscriptions(client, true) {
+		p.PUnsubscribe(client, pattern)
+	}
+}
+
+// Subscriptions returns the sorted channels (or patterns) a client is
+// subscribed to
+func (p *pubsub) Subscriptions(client *Client, patterns bool) []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	own := client.channels
+	if patterns {
+		own = client.patterns
+	}
+
+	names := make([]string, 0, len(own))
+	for name := range own {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Publish delivers a message to all subscribers of a channel, returns the
+// number of receivers
+func (p *pubsub) Publish(channel string, payload []byte) int {
+	type delivery struct {
+		client  *Client
+		pattern string
+		matched bool
+	}
+
+	p.mutex.RLock()
+	deliveries := make([]delivery, 0, len(p.channels[channel]))
+	for client := range p.channels[channel] {
+		deliveries = append(deliveries, delivery{client: client})
+	}
+	for pattern, subs := range p.patterns {
+		if !matchGlob(pattern, channel) {
+			continue
+		}
+		for client := range subs {
+			deliveries = append(deliveries, delivery{client: client, pattern: pattern, matched: true})
+		}
+	}
+	p.mutex.RUnlock()
+
+	var messages [RESP3 + 1][]byte
+	for _, d := range deliveries {
+		proto := d.client.Protocol()
+		if d.matched {
+			_ = d.client.push(pushMessage(proto, []byte("pmessage"), []byte(d.pattern), []byte(channel), payload))
+			continue
+		}
+		if messages[proto] == nil {
+			messages[proto] = pushMessage(proto, []byte("message"), []byte(channel), payload)
+		}
+		_ = d.client.push(messages[proto])
+	}
+	return len(deliveries)
+}
+
+// Channels returns the sorted list of active channels, optionally filtered
+// by a pattern
+func (p *pubsub) Channels(pattern string) []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	names := make([]string, 0, len(p.channels))
+	for name := range p.channels {
+		if pattern == "" || matchGlob(pattern, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NumSub returns the number of subscribers of a channel
+func (p *pubsub) NumSub(channel string) int {
+	p.mutex.RLock()
+	n := len(p.channels[channel])
+	p.mutex.RUnlock()
+	return n
+}
+
+// NumPat returns the number of unique patterns subscribed to
+func (p *pubsub) NumPat() int {
+	p.mutex.RLock()
+	n := len(p.patterns)
+	p.mutex.RUnlock()
+	return n
+}
+
+func (p *pubsub) add(index map[string]subscribers, own *map[string]struct{}, client *Client, name string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	subs, ok := index[name]
+	if !ok {
+		subs = make(subscribers)
+		index[name] = subs
+	}
+	subs[client] = struct{}{}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if *own == nil {
+		*own = make(map[string]struct{})
+	}
+	(*own)[name] = struct{}{}
+	return len(client.channels) + len(client.patterns)
+}
+
+func (p *pubsub) remove(index map[string]subscribers, own *map[string]struct{}, client *Client, name string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if subs, ok := index[name]; ok {
+		delete(subs, client)
+		if len(subs) == 0 {
+			delete(index, name)
+		}
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	delete(*own, name)
+	return len(client.channels) + len(client.patterns)
+}
+
+// pushMessage generates an out-of-band message
+func pushMessage(proto int, parts ...[]byte) []byte {
+	buf := new(bytes.Buffer)
+	w := NewResponder(buf)
+	w.proto = proto
+	w.WritePushLen(len(parts))
+	for _, p := range parts {
+		w.WriteBytes(p)
+	}
+	_ = w.release()
+	return buf.Bytes()
+}
+
+// ------------------------------------------------------------------------
+
+var errNoClient = ClientError("command requires a client connection")
+
+// Publish delivers a payload to all clients subscribed to the channel,
+// directly or via a pattern. Returns the number of receivers.
+func (srv *Server) Publish(channel string, payload []byte) int {
+	return srv.pubsub.Publish(channel, payload)
+}
+
+func (srv *Server) serveSubscribe(out *Responder, req *Request) error {
+	if len(req.Args) == 0 {
+		return req.WrongNumberOfArgs()
+	} else if req.client == nil {
+		return errNoClient
+	}
+
+	for _, channel := range req.Args {
+		n := srv.pubsub.Subscribe(req.client, channel)
+		writeSubscription(out, "subscribe", channel, n)
+	}
+	return nil
+}
+
+func (srv *Server) servePSubscribe(out *Responder, req *Request) error {
+	if len(req.Args) == 0 {
+		return req.WrongNumberOfArgs()
+	} else if req.client == nil {
+		return errNoClient
+	}
+
+	for _, pattern := range req.Args {
+		n := srv.pubsub.PSubscribe(req.client, pattern)
+		writeSubscription(out, "psubscribe", pattern, n)
+	}
+	return nil
+}
+
+func (srv *Server) serveUnsubscribe(out *Responder, req *Request) error {
+	if req.client == nil {
+		return errNoClient
+	}
+
+	channels := req.Args
+	if len(channels) == 0 {
+		channels = srv.pubsub.Subscriptions(req.client, false)
+	}
+	if len(channels) == 0 {
+		writeNoSubscription(out, "unsubscribe")
+		return nil
+	}
+
+	for _, channel := range channels {
+		n := srv.pubsub.Unsubscribe(req.client, channel)
+		writeSubscription(out, "unsubscribe", channel, n)
+	}
+	return nil
+}
+
+func (srv *Server) servePUnsubscribe(out *Responder, req *Request) error {
+	if req.client == nil {
+		return errNoClient
+	}
+
+	patterns := req.Args
+	if len(patterns) == 0 {
+		patterns = srv.pubsub.Subscriptions(req.client, true)
+	}
+	if len(patterns) == 0 {
+		writeNoSubscription(out, "punsubscribe")
+		return nil
+	}
+
+	for _, pattern := range patterns {
+		n := srv.pubsub.PUnsubscribe(req.client, pattern)
+		writeSubscription(out, "punsubscribe", pattern, n)
+	}
+	return nil
+}
+
+func (srv *Server) servePublish(out *Responder, req *Request) error {
+	if len(req.Args) != 2 {
+		return req.WrongNumberOfArgs()
+	}
+
+	out.WriteInt(srv.Publish(req.Args[0], []byte(req.Args[1])))
+	return nil
+}
+
+// pubsubRouter creates the PUBSUB subcommand router
+func (srv *Server) pubsubRouter() *SubCommands {
+	subs := NewSubCommands()
+	subs.HandleCommand(CommandInfo{
+		Name:    "channels",
+		Arity:   -2,
+		Summary: "Return the currently active channels matching a <pattern> (default: '*').",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		if len(req.Args) > 1 {
+			return WrongNumberOfArgs("pubsub|channels")
+		}
+
+		pattern := ""
+		if len(req.Args) == 1 {
+			pattern = req.Args[0]
+		}
+		out.WriteStringBulk(srv.pubsub.Channels(pattern))
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "numsub",
+		Arity:   -2,
+		Summary: "Return the number of subscribers for the specified channels, excluding pattern subscriptions.",
+	}, HandlerFunc(func(out *Responder, req *Request) error {
+		out.WriteMapLen(len(req.Args))
+		for _, channel := range req.Args {
+			out.WriteString(channel)
+			out.WriteInt(srv.pubsub.NumSub(channel))
+		}
+		return nil
+	}))
+	subs.HandleCommand(CommandInfo{
+		Name:    "numpat",
+		Arity:   2,
+		Summary: "Return number of subscriptions to patterns.",
+	}, HandlerFunc(func(out *Responder, _ *Request) error {
+		out.WriteInt(srv.pubsub.NumPat())
+		return nil
+	}))
+	return subs
+}
+
+// writeSubscription writes a subscription confirmation
+func writeSubscription(out *Responder, kind, name string, n int) {
+	out.WritePushLen(3)
+	out.WriteString(kind)
+	out.WriteString(name)
+	out.WriteInt(n)
+}
+
+// writeNoSubscription writes a confirmation when there was nothing to
+// unsubscribe from
+func writeNoSubscription(out *Responder, kind string) {
+	out.WritePushLen(3)
+	out.WriteString(kind)
+	out.WriteNull()
+	out.WriteZero()
+}
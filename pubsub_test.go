@@ -0,0 +1,120 @@
+package redeo
+
+import (
+	"bufio"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PubSub", func() {
+	var subject *Server
+	var conn *mockConn
+	var client *Client
+
+	BeforeEach(func() {
+		subject = NewServer(nil)
+		conn = &mockConn{}
+		client = NewClient(conn)
+	})
+
+	It("should subscribe to channels", func() {
+		Expect(apply(subject, client, "subscribe", "a", "b")).To(Equal("*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$1\r\nb\r\n:2\r\n"))
+		Expect(client.subscriptions()).To(Equal(2))
+		Expect(subject.pubsub.Channels("")).To(Equal([]string{"a", "b"}))
+
+		Expect(subject.Publish("a", []byte("hi"))).To(Equal(1))
+		Expect(subject.Publish("c", []byte("hi"))).To(Equal(0))
+		Eventually(conn.String).Should(Equal("*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$2\r\nhi\r\n"))
+	})
+
+	It("should subscribe to patterns", func() {
+		Expect(apply(subject, client, "psubscribe", "news.*")).To(Equal("*3\r\n$10\r\npsubscribe\r\n$6\r\nnews.*\r\n:1\r\n"))
+
+		Expect(subject.Publish("news.tech", []byte("hi"))).To(Equal(1))
+		Expect(subject.Publish("sport", []byte("hi"))).To(Equal(0))
+		Eventually(conn.String).Should(Equal("*4\r\n$8\r\npmessage\r\n$6\r\nnews.*\r\n$9\r\nnews.tech\r\n$2\r\nhi\r\n"))
+	})
+
+	It("should unsubscribe", func() {
+		apply(subject, client, "subscribe", "a", "b")
+		apply(subject, client, "psubscribe", "c*")
+
+		Expect(apply(subject, client, "unsubscribe", "a")).To(Equal("*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:2\r\n"))
+		Expect(apply(subject, client, "unsubscribe")).To(Equal("*3\r\n$11\r\nunsubscribe\r\n$1\r\nb\r\n:1\r\n"))
+		Expect(apply(subject, client, "unsubscribe")).To(Equal("*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n"))
+		Expect(apply(subject, client, "punsubscribe")).To(Equal("*3\r\n$12\r\npunsubscribe\r\n$2\r\nc*\r\n:0\r\n"))
+		Expect(subject.pubsub.Channels("")).To(BeEmpty())
+		Expect(subject.pubsub.NumPat()).To(Equal(0))
+	})
+
+	It("should remove all subscriptions", func() {
+		apply(subject, client, "subscribe", "a", "b")
+		apply(subject, client, "psubscribe", "c*")
+
+		subject.pubsub.UnsubscribeAll(client)
+		Expect(client.subscriptions()).To(Equal(0))
+		Expect(subject.Publish("a", []byte("hi"))).To(Equal(0))
+		Expect(subject.pubsub.NumPat()).To(Equal(0))
+	})
+
+	It("should restrict RESP2 clients in subscribed mode", func() {
+		subject.HandleFunc("get", func(out *Responder, _ *Request) error {
+			out.WriteNil()
+			return nil
+		})
+		apply(subject, client, "subscribe", "a")
+
+		Expect(apply(subject, client, "get", "x")).To(Equal("-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n"))
+		Expect(apply(subject, client, "unsubscribe", "a")).To(HavePrefix("*3\r\n"))
+		Expect(apply(subject, client, "get", "x")).To(Equal("$-1\r\n"))
+	})
+
+	It("should use push messages for RESP3 clients", func() {
+		subject.HandleFunc("get", func(out *Responder, _ *Request) error {
+			out.WriteNull()
+			return nil
+		})
+		client.setProtocol(RESP3)
+
+		Expect(apply(subject, client, "subscribe", "a")).To(Equal(">3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n"))
+		Expect(apply(subject, client, "get", "x")).To(Equal("_\r\n"))
+
+		Expect(subject.Publish("a", []byte("hi"))).To(Equal(1))
+		Eventually(conn.String).Should(Equal(">3\r\n$7\r\nmessage\r\n$1\r\na\r\n$2\r\nhi\r\n"))
+	})
+
+	It("should defer messages while clients are busy", func() {
+		apply(subject, client, "subscribe", "a")
+
+		client.beginCommand()
+		Expect(subject.Publish("a", []byte("hi"))).To(Equal(1))
+		Expect(conn.Len()).To(Equal(0))
+
+		Expect(client.endCommand(bufio.NewWriter(conn))).To(Succeed())
+		Expect(conn.String()).To(Equal("*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$2\r\nhi\r\n"))
+	})
+
+	It("should publish", func() {
+		subject.pubsub.Subscribe(NewClient(&mockConn{}), "a")
+		Expect(apply(subject, client, "publish", "a", "hi")).To(Equal(":1\r\n"))
+		Expect(apply(subject, client, "publish", "a")).To(Equal("-ERR wrong number of arguments for 'publish' command\r\n"))
+	})
+
+	It("should introspect", func() {
+		other := NewClient(&mockConn{})
+		subject.pubsub.Subscribe(other, "news.tech")
+		subject.pubsub.Subscribe(other, "sport")
+		subject.pubsub.PSubscribe(other, "news.*")
+		apply(subject, client, "subscribe", "news.tech")
+		apply(subject, client, "unsubscribe", "news.tech")
+
+		Expect(apply(subject, client, "pubsub", "channels")).To(Equal("*2\r\n$9\r\nnews.tech\r\n$5\r\nsport\r\n"))
+		Expect(apply(subject, client, "pubsub", "CHANNELS", "news.*")).To(Equal("*1\r\n$9\r\nnews.tech\r\n"))
+		Expect(apply(subject, client, "pubsub", "numsub", "news.tech", "x")).To(Equal("*4\r\n$9\r\nnews.tech\r\n:1\r\n$1\r\nx\r\n:0\r\n"))
+		Expect(apply(subject, client, "pubsub", "numpat")).To(Equal(":1\r\n"))
+		Expect(apply(subject, client, "pubsub", "x")).To(Equal("-ERR unknown subcommand 'x'. Try PUBSUB HELP.\r\n"))
+		Expect(apply(subject, client, "pubsub", "numpat", "x")).To(Equal("-ERR wrong number of arguments for 'pubsub|numpat' command\r\n"))
+	})
+
+})
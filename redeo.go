@@ -4,9 +4,27 @@ import (
 	"errors"
 )
 
-// Protocol errors
+// ErrInvalidRequest is a generic protocol error.
+//
+// Deprecated: malformed requests result in a ProtocolError instead.
 var ErrInvalidRequest = errors.New("redeo: invalid request")
 
+// ProtocolError is returned when a client sends a malformed request. The
+// client receives the error as a reply and is disconnected.
+type ProtocolError string
+
+// Error returns the error message, e.g. "Protocol error: invalid bulk length"
+func (e ProtocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+// ErrNoTLSConfig is returned when TLS connections are served without a
+// TLSConfig
+var ErrNoTLSConfig = errors.New("redeo: TLS config required")
+
+// ErrServerClosed is returned by Serve after a call to Shutdown or Close
+var ErrServerClosed = errors.New("redeo: server closed")
+
 // Client errors can be returned by handlers.
 // Unlike other errors, client errors do not disconnect the client
 type ClientError string
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,10 +28,42 @@ func (w *badWriter) Write(p []byte) (int, error) {
 	return 0, io.EOF
 }
 
+// Applies a command and returns the reply
+func apply(srv *Server, client *Client, name string, args ...string) string {
+	w := &bytes.Buffer{}
+	Expect(srv.apply(&Request{Name: name, Args: args, client: client}, w)).To(BeTrue())
+	return w.String()
+}
+
 type mockConn struct {
 	bytes.Buffer
 	Port   int
 	closed bool
+	mutex  sync.Mutex
+}
+
+func (m *mockConn) Read(p []byte) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Buffer.Read(p)
+}
+
+func (m *mockConn) Write(p []byte) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Buffer.Write(p)
+}
+
+func (m *mockConn) String() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Buffer.String()
+}
+
+func (m *mockConn) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Buffer.Len()
 }
 
 func (m *mockConn) Close() error { m.closed = true; return nil }
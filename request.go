@@ -2,9 +2,24 @@ package redeo
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"io"
-	"strconv"
+	"io/ioutil"
 	"strings"
+	"sync"
+	"time"
+)
+
+// Argument buffers larger than this are not retained between requests
+const maxRetainedArgBuffer = 64 * 1024
+
+// Default protocol limits
+const (
+	defaultMaxBulkLen    = 512 * 1024 * 1024
+	defaultMaxArgs       = 1024 * 1024
+	defaultMaxInlineSize = 64 * 1024
 )
 
 // Request contains a command, arguments, and client information
@@ -13,7 +28,21 @@ type Request struct {
 	Args []string    `json:"args,omitempty"`
 	Ctx  interface{} `json:"ctx,omitempty"`
 
-	client *Client
+	argv      [][]byte
+	rawName   []byte
+	stream    *io.LimitedReader
+	streamLen int64
+	client    *Client
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	deadline time.Time
+
+	// set for commands executed by EXEC
+	inTx bool
+
+	// set for subcommands, which share the context of the parent request
+	parent *Request
 }
 
 // Client returns the client
@@ -21,6 +50,66 @@ func (r *Request) Client() *Client {
 	return r.client
 }
 
+// InTransaction reports if the request is executed by EXEC, while the
+// transaction lock is already held
+func (r *Request) InTransaction() bool {
+	return r.inTx
+}
+
+// Context returns the request context. It is cancelled when the client
+// disconnects or is closed, when the server is closed, when a graceful
+// Shutdown expires or when the Config.CommandTimeout expires.
+// Long-running handlers should observe it.
+func (r *Request) Context() context.Context {
+	if r.parent != nil {
+		return r.parent.Context()
+	}
+	if r.ctx == nil {
+		r.ctx = context.Background()
+		if r.client != nil {
+			r.ctx = r.client.context()
+		}
+		if !r.deadline.IsZero() {
+			r.ctx, r.cancel = context.WithDeadline(r.ctx, r.deadline)
+		}
+	}
+	return r.ctx
+}
+
+// NumArgs returns the number of arguments
+func (r *Request) NumArgs() int {
+	if r.argv != nil {
+		return len(r.argv)
+	}
+	return len(r.Args)
+}
+
+// Arg returns the i-th argument as a binary-safe byte slice.
+//
+// For requests received by the server, the slice references the
+// connection's read buffer rather than a copy. It must not be modified and
+// is only valid until the handler returns, after which the buffer is
+// recycled for the next request. Copy the data to retain it, or use Args.
+func (r *Request) Arg(i int) []byte {
+	if r.argv != nil {
+		return r.argv[i]
+	}
+	return []byte(r.Args[i])
+}
+
+// Stream returns a reader for the final argument of commands registered
+// with CommandInfo.Streaming, together with the declared length of the
+// argument. The streamed argument is not included in Args.
+//
+// The reader is only valid until the handler returns, unread data is
+// discarded. Returns a nil reader for all other commands.
+func (r *Request) Stream() (io.Reader, int64) {
+	if r.stream == nil {
+		return nil, 0
+	}
+	return r.stream, r.streamLen
+}
+
 // WrongNumberOfArgs generates a standard client error
 func (r *Request) WrongNumberOfArgs() ClientError {
 	return WrongNumberOfArgs(r.Name)
@@ -31,54 +120,391 @@ func (r *Request) UnknownCommand() ClientError {
 	return UnknownCommand(r.Name)
 }
 
+// Derives Args from the binary arguments, unless already present
+func (r *Request) deriveArgs() {
+	if r.Args != nil || r.argv == nil {
+		return
+	}
+
+	r.Args = make([]string, len(r.argv))
+	for i, arg := range r.argv {
+		r.Args[i] = string(arg)
+	}
+}
+
+// Reports if the command timeout has expired
+func (r *Request) timedOut() bool {
+	return !r.deadline.IsZero() && !time.Now().Before(r.deadline)
+}
+
+// Releases context resources
+func (r *Request) releaseContext() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// Moves the final argument into the stream, unless already streamed
+func (r *Request) detachStream() {
+	n := r.NumArgs()
+	if r.stream != nil || n == 0 {
+		return
+	}
+
+	last := r.Arg(n - 1)
+	r.stream = &io.LimitedReader{R: bytes.NewReader(last), N: int64(len(last))}
+	r.streamLen = int64(len(last))
+	if r.Args != nil {
+		r.Args = r.Args[:n-1]
+	}
+	if r.argv != nil {
+		r.argv = r.argv[:n-1]
+	}
+}
+
 // ParseRequest parses a new request from a buffered connection
 func ParseRequest(rd *bufio.Reader) (*Request, error) {
-	line, err := rd.ReadString('\n')
-	if err != nil || len(line) < 3 {
-		return nil, io.EOF
+	r := requestReader{rd: rd, limits: defaultRequestLimits}
+	req, err := r.Next()
+	if err != nil {
+		return nil, err
 	}
 
-	// Truncate CRLF
-	line = line[:len(line)-2]
+	req.deriveArgs()
+	req.argv = nil
+	req.rawName = nil
+	return req, nil
+}
+
+// ------------------------------------------------------------------------
+
+var requestReaderPool sync.Pool
+
+var defaultRequestLimits = requestLimits{
+	bulkLen: defaultMaxBulkLen,
+	args:    defaultMaxArgs,
+	inline:  defaultMaxInlineSize,
+}
+
+// requestLimits protect against oversized requests
+type requestLimits struct {
+	bulkLen int64
+	args    int
+	inline  int
+}
+
+var errLineTooLong = errors.New("redeo: line too long")
 
-	// Return if inline
-	if line[0] != codeBulkLen {
-		return &Request{Name: strings.ToLower(line)}, nil
+// requestReader parses requests from a buffered connection, reading all
+// arguments into a single buffer which is reused for every request
+type requestReader struct {
+	rd   *bufio.Reader
+	buf  []byte
+	line []byte
+	offs []int
+
+	limits requestLimits
+
+	// streaming reports if the final argument of a command should be
+	// streamed rather than buffered
+	streaming func(name string) bool
+	stream    *io.LimitedReader
+}
+
+// newRequestReader returns a pooled reader
+func newRequestReader(rd *bufio.Reader) *requestReader {
+	if v := requestReaderPool.Get(); v != nil {
+		r := v.(*requestReader)
+		r.rd = rd
+		r.limits = defaultRequestLimits
+		return r
 	}
+	return &requestReader{rd: rd, limits: defaultRequestLimits}
+}
 
-	argc, err := strconv.Atoi(line[1:])
-	if err != nil {
-		return nil, ErrInvalidRequest
+// Next parses the next request. Binary arguments reference the internal
+// buffer and are only valid until the next call
+func (r *requestReader) Next() (*Request, error) {
+	if err := r.skipStream(); err != nil {
+		return nil, err
 	}
+	if cap(r.buf) > maxRetainedArgBuffer {
+		r.buf = nil
+	}
+	r.buf = r.buf[:0]
+	r.offs = r.offs[:0]
+
+	for {
+		line, err := r.readLine()
+		if err == errLineTooLong {
+			if line[0] == codeBulkLen {
+				return nil, ProtocolError("too big mbulk count string")
+			}
+			return nil, ProtocolError("too big inline request")
+		} else if err == io.EOF && len(line) != 0 {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		if line[0] == codeBulkLen {
+			// Skip empty multi-bulk requests
+			if req, err := r.readMultiBulk(line); req != nil || err != nil {
+				return req, err
+			}
+			continue
+		}
 
-	args := make([]string, argc)
-	for i := 0; i < argc; i++ {
-		if args[i], err = parseArgument(rd); err != nil {
+		// Skip blank inline requests
+		if err := r.splitInline(line); err != nil {
 			return nil, err
+		} else if n := len(r.offs) / 2; n > r.limits.args {
+			return nil, ProtocolError("invalid multibulk length")
+		} else if n != 0 {
+			return r.request(n), nil
 		}
 	}
-	return &Request{Name: strings.ToLower(args[0]), Args: args[1:]}, nil
 }
 
-func parseArgument(rd *bufio.Reader) (string, error) {
-	line, err := rd.ReadString('\n')
-	if err != nil {
-		return "", io.EOF
-	} else if len(line) < 3 {
-		return "", io.EOF
+// readMultiBulk reads a multi-bulk request. Returns a nil request for
+// empty requests
+func (r *requestReader) readMultiBulk(line []byte) (*Request, error) {
+	argc, ok := 0, false
+	if n := len(line); n > 2 && line[n-2] == '\r' {
+		argc, ok = atoi(line[1 : n-2])
+	}
+	if !ok || argc > r.limits.args {
+		return nil, ProtocolError("invalid multibulk length")
+	} else if argc < 1 {
+		return nil, nil
+	}
+
+	if err := r.readArgument(); err != nil {
+		return nil, err
+	}
+
+	streaming := argc > 1 && r.streaming != nil && r.streaming(strings.ToLower(string(r.buf)))
+	if streaming {
+		argc--
+	}
+	for i := 1; i < argc; i++ {
+		if err := r.readArgument(); err != nil {
+			return nil, err
+		}
+	}
+
+	req := r.request(argc)
+	if streaming {
+		n, err := r.readArgumentLen()
+		if err != nil {
+			return nil, err
+		}
+		r.stream = &io.LimitedReader{R: r.rd, N: n}
+		req.stream, req.streamLen = r.stream, n
+	}
+	return req, nil
+}
+
+// request builds a request from the first n buffered arguments
+func (r *requestReader) request(n int) *Request {
+	name := r.buf[r.offs[0]:r.offs[1]:r.offs[1]]
+	req := &Request{
+		Name:    strings.ToLower(string(name)),
+		argv:    make([][]byte, n-1),
+		rawName: name,
+	}
+	for i := range req.argv {
+		start, end := r.offs[2*i+2], r.offs[2*i+3]
+		req.argv[i] = r.buf[start:end:end]
+	}
+	return req
+}
+
+// release returns the reader to the pool
+func (r *requestReader) release() {
+	r.rd = nil
+	r.buf = r.buf[:0]
+	r.line = nil
+	r.offs = r.offs[:0]
+	r.streaming = nil
+	r.stream = nil
+	requestReaderPool.Put(r)
+}
+
+// hasRequest reports if a complete request is buffered, so that Next
+// will return without reading from the connection
+func (r *requestReader) hasRequest() bool {
+	n := r.rd.Buffered()
+	if n == 0 || r.stream != nil {
+		return false
+	}
+
+	buf, _ := r.rd.Peek(n)
+	for {
+		line, rest, ok := cutLine(buf)
+		if !ok {
+			return false
+		}
+		buf = rest
+
+		// Skip blank inline requests
+		if line[0] != codeBulkLen {
+			if len(bytes.TrimSpace(line)) != 0 {
+				return true
+			}
+			continue
+		}
+
+		// Malformed headers fail without blocking
+		argc, ok := atoi(bytes.TrimRight(line[1:], "\r\n"))
+		if !ok {
+			return true
+		} else if argc < 1 {
+			continue
+		}
+
+		for i := 0; i < argc; i++ {
+			if line, buf, ok = cutLine(buf); !ok {
+				return false
+			} else if line[0] != codeStrLen {
+				return true
+			}
+
+			blen, ok := atoi(bytes.TrimRight(line[1:], "\r\n"))
+			if !ok || blen < 0 {
+				return true
+			} else if len(buf) < blen+2 {
+				return false
+			}
+			buf = buf[blen+2:]
+		}
+		return true
+	}
+}
+
+// readLine reads a full line, including the line break. Returns
+// errLineTooLong when the line exceeds the inline size limit.
+func (r *requestReader) readLine() ([]byte, error) {
+	line, err := r.rd.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		if len(line) > r.limits.inline+2 {
+			return line, errLineTooLong
+		}
+		return line, err
+	}
+
+	r.line = append(r.line[:0], line...)
+	for err == bufio.ErrBufferFull {
+		if len(r.line) > r.limits.inline+2 {
+			return r.line, errLineTooLong
+		}
+		line, err = r.rd.ReadSlice('\n')
+		r.line = append(r.line, line...)
+	}
+	if len(r.line) > r.limits.inline+2 {
+		return r.line, errLineTooLong
+	}
+	return r.line, err
+}
+
+// skipStream discards the unread remainder of a streamed argument
+func (r *requestReader) skipStream() error {
+	if r.stream == nil {
+		return nil
+	}
+
+	n := r.stream.N + 2
+	r.stream.N = 0
+	r.stream = nil
+	if _, err := io.CopyN(ioutil.Discard, r.rd, n); err != nil {
+		return unexpectedEOF(err)
+	}
+	return nil
+}
+
+// readArgumentLen reads the length header of a bulk argument
+func (r *requestReader) readArgumentLen() (int64, error) {
+	line, err := r.readLine()
+	if err == errLineTooLong {
+		return 0, ProtocolError("too big bulk count string")
+	} else if err != nil {
+		return 0, unexpectedEOF(err)
 	} else if line[0] != codeStrLen {
-		return "", ErrInvalidRequest
+		return 0, ProtocolError("expected '$', got '" + string(line[0]) + "'")
 	}
 
-	blen, err := strconv.Atoi(line[1 : len(line)-2])
+	blen, ok := 0, false
+	if n := len(line); n > 2 && line[n-2] == '\r' {
+		blen, ok = atoi(line[1 : n-2])
+	}
+	if !ok || blen < 0 || int64(blen) > r.limits.bulkLen {
+		return 0, ProtocolError("invalid bulk length")
+	}
+	return int64(blen), nil
+}
+
+// readArgument reads a bulk argument into the buffer
+func (r *requestReader) readArgument() error {
+	n, err := r.readArgumentLen()
 	if err != nil {
-		return "", ErrInvalidRequest
+		return err
 	}
+	blen := int(n)
 
-	buf := make([]byte, blen+2)
-	if _, err := io.ReadFull(rd, buf); err != nil {
-		return "", io.EOF
+	start := len(r.buf)
+	end := start + blen + 2
+	if end > cap(r.buf) {
+		buf := make([]byte, start, 2*cap(r.buf)+blen+2)
+		copy(buf, r.buf)
+		r.buf = buf
 	}
+	r.buf = r.buf[:end]
 
-	return string(buf[:blen]), nil
+	if _, err := io.ReadFull(r.rd, r.buf[start:end]); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	r.buf = r.buf[:end-2]
+	r.offs = append(r.offs, start, end-2)
+	return nil
+}
+
+// cutLine splits the first line, including the line break
+func cutLine(buf []byte) (line, rest []byte, ok bool) {
+	i := bytes.IndexByte(buf, '\n')
+	if i < 0 {
+		return nil, buf, false
+	}
+	return buf[:i+1], buf[i+1:], true
+}
+
+// unexpectedEOF converts io.EOF, for reads within a request
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// atoi parses a non-negative or negative decimal integer
+func atoi(b []byte) (int, bool) {
+	neg := len(b) != 0 && b[0] == '-'
+	if neg {
+		b = b[1:]
+	}
+	if len(b) == 0 || len(b) > 18 {
+		return 0, false
+	}
+
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
 }
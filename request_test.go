@@ -16,7 +16,8 @@ var _ = Describe("Request", func() {
 		m string
 		d string
 	}{
-		{Request{Name: "ping"}, "PiNg\r\n", "inline ping"},
+		{Request{Name: "ping", Args: []string{}}, "PiNg\r\n", "inline ping"},
+		{Request{Name: "set", Args: []string{"k", "v 1"}}, "SET k \"v 1\"\r\n", "inline set"},
 		{Request{Name: "ping", Args: []string{}}, "*1\r\n$4\r\nPiNg\r\n", "bulk ping"},
 		{Request{Name: "get", Args: []string{"Xy"}}, "*2\r\n$3\r\nGET\r\n$2\r\nXy\r\n", "get"},
 		{Request{Name: "set", Args: []string{"k\r\ney", "va\r\nl"}}, "*3\r\n$3\r\nSET\r\n$5\r\nk\r\ney\r\n$5\r\nva\r\nl\r\n", "set"},
@@ -29,12 +30,14 @@ var _ = Describe("Request", func() {
 	}{
 		{io.EOF, "", "blank"},
 		{io.EOF, "\r\n", "blank with CRLF"},
-		{ErrInvalidRequest, "*x\r\n", "no bulk length"},
-		{ErrInvalidRequest, "*1\r\nping\r\n", "no argument length"},
-		{io.EOF, "*2\r\n$3\r\nget\r\n", "truncated message"},
-		{ErrInvalidRequest, "*2\r\n$x\r\nget\r\n", "missing argument len"},
-		{io.EOF, "*2\r\n$3\r\nge", "truncated argument"},
-		{ErrInvalidRequest, "*2\n$3\nget\n$1\nx\n", "wrong line breaks"},
+		{io.EOF, "*0\r\n", "empty multi-bulk"},
+		{io.ErrUnexpectedEOF, "PING", "truncated inline"},
+		{ProtocolError("invalid multibulk length"), "*x\r\n", "no bulk length"},
+		{ProtocolError("expected '$', got 'p'"), "*1\r\nping\r\n", "no argument length"},
+		{io.ErrUnexpectedEOF, "*2\r\n$3\r\nget\r\n", "truncated message"},
+		{ProtocolError("invalid bulk length"), "*2\r\n$x\r\nget\r\n", "missing argument len"},
+		{io.ErrUnexpectedEOF, "*2\r\n$3\r\nge", "truncated argument"},
+		{ProtocolError("invalid multibulk length"), "*2\n$3\nget\n$1\nx\n", "wrong line breaks"},
 	}
 
 	It("should parse successfully, consuming the full message", func() {
@@ -81,6 +84,141 @@ var _ = Describe("Request", func() {
 		}
 	})
 
+	It("should provide binary argument access", func() {
+		req := &Request{Name: "set", Args: []string{"k", "v"}}
+		Expect(req.NumArgs()).To(Equal(2))
+		Expect(req.Arg(1)).To(Equal([]byte("v")))
+
+		rd := newRequestReader(bufio.NewReader(strings.NewReader("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$3\r\nv\x00l\r\n")))
+		defer rd.release()
+
+		req, err := rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Name).To(Equal("set"))
+		Expect(req.Args).To(BeNil())
+		Expect(req.NumArgs()).To(Equal(2))
+		Expect(req.Arg(0)).To(Equal([]byte("k")))
+		Expect(req.Arg(1)).To(Equal([]byte("v\x00l")))
+	})
+
+	It("should reuse argument buffers", func() {
+		rd := newRequestReader(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nget\r\n$3\r\nfoo\r\n*2\r\n$3\r\nget\r\n$3\r\nbar\r\n")))
+		defer rd.release()
+
+		req1, err := rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req1.Arg(0)).To(Equal([]byte("foo")))
+
+		req2, err := rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req2.Arg(0)).To(Equal([]byte("bar")))
+		Expect(req1.Arg(0)).To(Equal([]byte("bar")))
+	})
+
+	It("should stream final arguments", func() {
+		msg := "*3\r\n$3\r\nPUT\r\n$1\r\nk\r\n$10\r\n0123456789\r\n*1\r\n$4\r\nping\r\n"
+		rd := newRequestReader(bufio.NewReader(mockFD{s: msg}))
+		rd.streaming = func(name string) bool { return name == "put" }
+		defer rd.release()
+
+		req, err := rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Name).To(Equal("put"))
+		Expect(req.NumArgs()).To(Equal(1))
+		Expect(req.Arg(0)).To(Equal([]byte("k")))
+
+		stream, n := req.Stream()
+		Expect(n).To(Equal(int64(10)))
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(stream, buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf)).To(Equal("0123"))
+
+		// skips the unread remainder
+		req, err = rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Name).To(Equal("ping"))
+		stream, _ = req.Stream()
+		Expect(stream).To(BeNil())
+	})
+
+	It("should reset pending streams on release", func() {
+		rd := newRequestReader(bufio.NewReader(strings.NewReader("*3\r\n$3\r\nPUT\r\n$1\r\nk\r\n$5\r\n01234\r\n")))
+		rd.streaming = func(name string) bool { return name == "put" }
+
+		_, err := rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rd.stream).NotTo(BeNil())
+		rd.release()
+		Expect(rd.stream).To(BeNil())
+		Expect(rd.streaming).To(BeNil())
+		Expect(rd.buf).To(BeEmpty())
+		Expect(rd.offs).To(BeEmpty())
+
+		// reuse, as if taken from the pool
+		rd.rd = bufio.NewReader(strings.NewReader("*2\r\n$3\r\nget\r\n$1\r\nk\r\n"))
+
+		req, err := rd.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Name).To(Equal("get"))
+		Expect(req.NumArgs()).To(Equal(1))
+		Expect(req.Arg(0)).To(Equal([]byte("k")))
+	})
+
+	It("should enforce protocol limits", func() {
+		limits := requestLimits{bulkLen: 8, args: 3, inline: 16}
+		cases := []struct {
+			m string
+			e error
+		}{
+			{"*3\r\n$3\r\nset\r\n$1\r\nk\r\n$8\r\n12345678\r\n", nil},
+			{"*3\r\n$3\r\nset\r\n$1\r\nk\r\n$9\r\n123456789\r\n", ProtocolError("invalid bulk length")},
+			{"*3\r\n$3\r\nset\r\n$1\r\nk\r\n$-1\r\n", ProtocolError("invalid bulk length")},
+			{"*4\r\n$3\r\nset\r\n", ProtocolError("invalid multibulk length")},
+			{"*2147483647\r\n", ProtocolError("invalid multibulk length")},
+			{"ping 0123456789abcdef\r\n", ProtocolError("too big inline request")},
+			{"*1" + strings.Repeat("0", 5000) + "\r\n", ProtocolError("too big mbulk count string")},
+			{"*2\r\n$3\r\nget\r\n$" + strings.Repeat("1", 20) + "\r\n", ProtocolError("too big bulk count string")},
+		}
+
+		for _, c := range cases {
+			rd := requestReader{rd: bufio.NewReader(strings.NewReader(c.m)), limits: limits}
+			if _, err := rd.Next(); c.e == nil {
+				Expect(err).NotTo(HaveOccurred(), c.m)
+			} else {
+				Expect(err).To(Equal(c.e), c.m)
+			}
+		}
+	})
+
+	It("should detect buffered requests", func() {
+		cases := []struct {
+			m  string
+			ok bool
+		}{
+			{"", false},
+			{"PING\r\nPI", false},
+			{"PING\r\nPING\r\n", true},
+			{"PING\r\n\r\n  \r\n", false},
+			{"PING\r\n*0\r\n", false},
+			{"PING\r\n*2\r\n$3\r\nget\r\n$1\r\n", false},
+			{"PING\r\n*2\r\n$3\r\nget\r\n$1\r\nx", false},
+			{"PING\r\n*2\r\n$3\r\nget\r\n$1\r\nx\r\n", true},
+			{"PING\r\n*x\r\n", true},
+			{"PING\r\n*1\r\nping\r\n", true},
+		}
+
+		for _, c := range cases {
+			rd := newRequestReader(bufio.NewReader(strings.NewReader(c.m)))
+			if c.m != "" {
+				_, err := rd.Next()
+				Expect(err).NotTo(HaveOccurred(), c.m)
+			}
+			Expect(rd.hasRequest()).To(Equal(c.ok), c.m)
+			rd.release()
+		}
+	})
+
 	It("should fail on invalid inputs", func() {
 		for _, c := range failureCases {
 			req, err := ParseRequest(bufio.NewReader(strings.NewReader(c.m)))
@@ -103,6 +241,22 @@ func BenchmarkParseRequest_Bulk(b *testing.B) {
 	}
 }
 
+func BenchmarkRequestReader_Bulk(b *testing.B) {
+	msg := strings.Repeat("*3\r\n$3\r\nset\r\n$1\r\nx\r\n$1024\r\n"+strings.Repeat("x", 1024)+"\r\n", 100)
+	src := strings.NewReader(msg)
+	rd := newRequestReader(bufio.NewReader(src))
+	defer rd.release()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rd.Next(); err == io.EOF {
+			src.Reset(msg)
+			rd.rd.Reset(src)
+		}
+	}
+}
+
 // MOCKS
 
 type mockFD struct {
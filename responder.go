@@ -3,17 +3,34 @@ package redeo
 import (
 	"bytes"
 	"io"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
 	"sync"
 )
 
+// Supported protocol versions
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
 const (
 	codeInline  = '+'
 	codeError   = '-'
 	codeFixnum  = ':'
 	codeStrLen  = '$'
 	codeBulkLen = '*'
+
+	codeNull     = '_'
+	codeDouble   = ','
+	codeBool     = '#'
+	codeBigNum   = '('
+	codeVerbatim = '='
+	codeMapLen   = '%'
+	codeSetLen   = '~'
+	codePushLen  = '>'
 )
 
 var (
@@ -22,16 +39,23 @@ var (
 	binZERO = []byte(":0\r\n")
 	binONE  = []byte(":1\r\n")
 	binNIL  = []byte("$-1\r\n")
+
+	binNULL  = []byte("_\r\n")
+	binTRUE  = []byte("#t\r\n")
+	binFALSE = []byte("#f\r\n")
 )
 
 var bufferPool sync.Pool
 
 // Responder generates client responses
 type Responder struct {
-	w io.Writer
+	w     io.Writer
+	proto int
 
 	buf *bytes.Buffer
 	err error
+
+	flushed bool
 }
 
 // NewResponder creates a new responder instance
@@ -44,9 +68,13 @@ func NewResponder(w io.Writer) *Responder {
 		buf = new(bytes.Buffer)
 	}
 
-	return &Responder{w: w, buf: buf}
+	return &Responder{w: w, proto: RESP2, buf: buf}
 }
 
+// Protocol returns the protocol version the responder is writing,
+// either RESP2 or RESP3
+func (r *Responder) Protocol() int { return r.proto }
+
 // WriteBulkLen writes a bulk length
 func (r *Responder) WriteBulkLen(n int) {
 	r.writeInline(codeBulkLen, strconv.Itoa(n))
@@ -180,6 +208,118 @@ func (r *Responder) WriteError(err error) {
 	r.WriteErrorString("ERR " + s)
 }
 
+// WriteMapLen writes a map length, followed by n key/value pairs.
+// RESP2 clients will receive a flat array of 2*n elements instead.
+func (r *Responder) WriteMapLen(n int) {
+	if r.proto < RESP3 {
+		r.WriteBulkLen(n * 2)
+		return
+	}
+	r.writeInline(codeMapLen, strconv.Itoa(n))
+}
+
+// WriteSetLen writes a set length, followed by n elements.
+// RESP2 clients will receive an array instead.
+func (r *Responder) WriteSetLen(n int) {
+	if r.proto < RESP3 {
+		r.WriteBulkLen(n)
+		return
+	}
+	r.writeInline(codeSetLen, strconv.Itoa(n))
+}
+
+// WritePushLen writes the length of an out-of-band push message, followed
+// by n elements. RESP2 clients will receive an array instead.
+func (r *Responder) WritePushLen(n int) {
+	if r.proto < RESP3 {
+		r.WriteBulkLen(n)
+		return
+	}
+	r.writeInline(codePushLen, strconv.Itoa(n))
+}
+
+// WriteNull writes a typed null. RESP2 clients will receive a nil bulk
+// string instead.
+func (r *Responder) WriteNull() {
+	if r.proto < RESP3 {
+		r.WriteNil()
+		return
+	}
+	r.writeRaw(binNULL)
+}
+
+// WriteFloat writes a double. RESP2 clients will receive a bulk string
+// instead.
+func (r *Responder) WriteFloat(f float64) {
+	var s string
+	switch {
+	case math.IsInf(f, 1):
+		s = "inf"
+	case math.IsInf(f, -1):
+		s = "-inf"
+	case math.IsNaN(f):
+		s = "nan"
+	default:
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	if r.proto < RESP3 {
+		r.WriteString(s)
+		return
+	}
+	r.writeInline(codeDouble, s)
+}
+
+// WriteBool writes a boolean. RESP2 clients will receive a 1 or 0 integer
+// instead.
+func (r *Responder) WriteBool(b bool) {
+	switch {
+	case r.proto < RESP3 && b:
+		r.WriteOne()
+	case r.proto < RESP3:
+		r.WriteZero()
+	case b:
+		r.writeRaw(binTRUE)
+	default:
+		r.writeRaw(binFALSE)
+	}
+}
+
+// WriteBigInt writes a big number. RESP2 clients will receive a bulk string
+// instead.
+func (r *Responder) WriteBigInt(n *big.Int) {
+	if r.proto < RESP3 {
+		r.WriteString(n.String())
+		return
+	}
+	r.writeInline(codeBigNum, n.String())
+}
+
+// WriteVerbatim writes a verbatim string with a three-letter format
+// identifier, such as "txt" or "mkd". Invalid formats are replaced by "txt".
+// RESP2 clients will receive a plain bulk string instead.
+func (r *Responder) WriteVerbatim(format, s string) {
+	if r.proto < RESP3 {
+		r.WriteString(s)
+		return
+	}
+	if len(format) != 3 {
+		format = "txt"
+	}
+
+	r.writeInline(codeVerbatim, strconv.Itoa(len(s)+4))
+	r.writeRaw([]byte(format))
+	r.writeRaw([]byte{':'})
+	if r.err != nil {
+		return
+	}
+	if _, err := r.buf.WriteString(s); err != nil {
+		r.err = err
+		return
+	}
+	r.writeRaw(binCRLF)
+}
+
 // WriteN streams data from a reader
 func (r *Responder) WriteN(rd io.Reader, n int64) {
 	if r.err != nil {
@@ -211,18 +351,35 @@ func (r *Responder) WriteN(rd io.Reader, n int64) {
 	}
 }
 
+// Flush sends buffered data to the client
 func (r *Responder) Flush() error {
+	if r.flushBuffer() == nil {
+		if f, ok := r.w.(flusher); ok {
+			r.err = f.Flush()
+		}
+	}
+	return r.err
+}
+
+// ------------------------------------------------------------------------
+
+// flusher is implemented by writers which buffer data themselves
+type flusher interface {
+	Flush() error
+}
+
+// Copies buffered data to the underlying writer, without flushing it
+func (r *Responder) flushBuffer() error {
 	if r.err == nil {
+		r.flushed = r.flushed || r.buf.Len() != 0
 		_, r.err = io.Copy(r.w, r.buf)
 		r.buf.Reset()
 	}
 	return r.err
 }
 
-// ------------------------------------------------------------------------
-
 func (r *Responder) release() error {
-	err := r.Flush()
+	err := r.flushBuffer()
 	bufferPool.Put(r.buf)
 	return err
 }
@@ -3,6 +3,8 @@ package redeo
 import (
 	"bytes"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"strings"
 	"testing"
 
@@ -107,6 +109,72 @@ var _ = Describe("Responder", func() {
 		Expect(out.String()).To(Equal("$-1\r\n$4\r\nECHO\r\n+OK\r\n"))
 	})
 
+	It("should downgrade RESP3 types for RESP2 clients", func() {
+		Expect(subject.Protocol()).To(Equal(RESP2))
+		subject.WriteMapLen(2)
+		subject.WriteSetLen(3)
+		subject.WriteNull()
+		subject.WriteFloat(1.5)
+		subject.WriteBool(true)
+		subject.WriteBool(false)
+		subject.WriteBigInt(big.NewInt(1234))
+		subject.WriteVerbatim("txt", "HI")
+		Expect(subject.Flush()).NotTo(HaveOccurred())
+		Expect(out.String()).To(Equal("*4\r\n*3\r\n$-1\r\n$3\r\n1.5\r\n:1\r\n:0\r\n$4\r\n1234\r\n$2\r\nHI\r\n"))
+	})
+
+	Describe("RESP3", func() {
+
+		BeforeEach(func() {
+			subject.proto = RESP3
+		})
+
+		It("should write maps and sets", func() {
+			subject.WriteMapLen(2)
+			subject.WriteSetLen(3)
+			Expect(subject.Flush()).NotTo(HaveOccurred())
+			Expect(out.String()).To(Equal("%2\r\n~3\r\n"))
+		})
+
+		It("should write nulls", func() {
+			subject.WriteNull()
+			Expect(subject.Flush()).NotTo(HaveOccurred())
+			Expect(out.String()).To(Equal("_\r\n"))
+		})
+
+		It("should write doubles", func() {
+			subject.WriteFloat(3.25)
+			subject.WriteFloat(10)
+			subject.WriteFloat(math.Inf(1))
+			subject.WriteFloat(math.Inf(-1))
+			subject.WriteFloat(math.NaN())
+			Expect(subject.Flush()).NotTo(HaveOccurred())
+			Expect(out.String()).To(Equal(",3.25\r\n,10\r\n,inf\r\n,-inf\r\n,nan\r\n"))
+		})
+
+		It("should write booleans", func() {
+			subject.WriteBool(true)
+			subject.WriteBool(false)
+			Expect(subject.Flush()).NotTo(HaveOccurred())
+			Expect(out.String()).To(Equal("#t\r\n#f\r\n"))
+		})
+
+		It("should write big numbers", func() {
+			n, _ := new(big.Int).SetString("3492890328409238509324850943850943825024385", 10)
+			subject.WriteBigInt(n)
+			Expect(subject.Flush()).NotTo(HaveOccurred())
+			Expect(out.String()).To(Equal("(3492890328409238509324850943850943825024385\r\n"))
+		})
+
+		It("should write verbatim strings", func() {
+			subject.WriteVerbatim("txt", "Some string")
+			subject.WriteVerbatim("x", "HI")
+			Expect(subject.Flush()).NotTo(HaveOccurred())
+			Expect(out.String()).To(Equal("=15\r\ntxt:Some string\r\n=6\r\ntxt:HI\r\n"))
+		})
+
+	})
+
 })
 
 func BenchmarkResponder_WriteOK(b *testing.B) {
@@ -2,21 +2,48 @@ package redeo
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"net"
 	"os"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Interval at which Shutdown polls for idle clients
+const shutdownPollInterval = 10 * time.Millisecond
+
 // Server configuration
 type Server struct {
 	config   *Config
 	info     *ServerInfo
-	commands map[string]Handler
-
-	tcp, unix net.Listener
-	clients   *clients
+	commands map[string]*command
+	builtins map[string]*command
+
+	middleware []Middleware
+
+	auth Authenticator
+	acl  *ACL
+
+	clients  *clients
+	pubsub   *pubsub
+	blocking *blocking
+	watches  *watches
+	monitors *monitors
+	txLock   sync.Locker
+	pause    pause
+
+	listeners  map[net.Listener]struct{}
+	inShutdown int32
+	mutex      sync.Mutex
+
+	// cancelled on Close and Shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+	reaper sync.Once
 }
 
 // NewServer creates a new server instance
@@ -26,12 +53,41 @@ func NewServer(config *Config) *Server {
 	}
 
 	clients := newClientRegistry()
-	return &Server{
+	blocking := newBlocking()
+	srv := &Server{
 		config:   config,
 		clients:  clients,
-		info:     newServerInfo(config, clients),
-		commands: make(map[string]Handler),
+		info:     newServerInfo(config, clients, blocking),
+		commands: make(map[string]*command),
+		builtins: make(map[string]*command),
+		pubsub:   newPubSub(),
+		blocking: blocking,
+		watches:  newWatches(),
+		monitors: newMonitors(),
+		txLock:   new(sync.Mutex),
+
+		listeners: make(map[net.Listener]struct{}),
 	}
+	srv.ctx, srv.cancel = context.WithCancel(context.Background())
+
+	srv.builtin(CommandInfo{Name: "auth", Arity: -2, Flags: FlagNoScript | FlagFast | FlagNoAuth | FlagSkipMonitor, Categories: []string{"fast", "connection"}}, HandlerFunc(srv.serveAuth))
+	srv.builtin(CommandInfo{Name: "hello", Arity: -1, Flags: FlagNoScript | FlagFast | FlagNoAuth | FlagSkipMonitor, Categories: []string{"fast", "connection"}}, HandlerFunc(srv.serveHello))
+	srv.builtin(CommandInfo{Name: "command", Arity: -1, Flags: FlagFast, Categories: []string{"slow", "connection"}}, HandlerFunc(srv.serveCommand))
+	srv.builtins["command"].subs = srv.commandRouter()
+	srv.builtin(CommandInfo{Name: "subscribe", Arity: -2, Flags: FlagPubSub | FlagNoScript, Categories: []string{"pubsub", "slow"}}, HandlerFunc(srv.serveSubscribe))
+	srv.builtin(CommandInfo{Name: "psubscribe", Arity: -2, Flags: FlagPubSub | FlagNoScript, Categories: []string{"pubsub", "slow"}}, HandlerFunc(srv.servePSubscribe))
+	srv.builtin(CommandInfo{Name: "unsubscribe", Arity: -1, Flags: FlagPubSub | FlagNoScript, Categories: []string{"pubsub", "slow"}}, HandlerFunc(srv.serveUnsubscribe))
+	srv.builtin(CommandInfo{Name: "punsubscribe", Arity: -1, Flags: FlagPubSub | FlagNoScript, Categories: []string{"pubsub", "slow"}}, HandlerFunc(srv.servePUnsubscribe))
+	srv.builtin(CommandInfo{Name: "publish", Arity: 3, Flags: FlagPubSub | FlagFast, Categories: []string{"pubsub", "fast"}}, HandlerFunc(srv.servePublish))
+	srv.builtin(CommandInfo{Name: "multi", Arity: 1, Flags: FlagNoScript | FlagFast, Categories: []string{"fast", "transaction"}}, HandlerFunc(srv.serveMulti))
+	srv.builtin(CommandInfo{Name: "exec", Arity: 1, Flags: FlagNoScript, Categories: []string{"slow", "transaction"}}, HandlerFunc(srv.serveExec))
+	srv.builtin(CommandInfo{Name: "discard", Arity: 1, Flags: FlagNoScript | FlagFast, Categories: []string{"fast", "transaction"}}, HandlerFunc(srv.serveDiscard))
+	srv.builtin(CommandInfo{Name: "watch", Arity: -2, Flags: FlagNoScript | FlagFast, FirstKey: 1, LastKey: -1, KeyStep: 1, Categories: []string{"fast", "transaction"}}, HandlerFunc(srv.serveWatch))
+	srv.builtin(CommandInfo{Name: "unwatch", Arity: 1, Flags: FlagNoScript | FlagFast, Categories: []string{"fast", "transaction"}}, HandlerFunc(srv.serveUnwatch))
+	srv.builtin(CommandInfo{Name: "client", Arity: -2, Flags: FlagAdmin | FlagNoScript, Categories: []string{"admin", "slow", "dangerous", "connection"}}, srv.clientRouter())
+	srv.builtin(CommandInfo{Name: "monitor", Arity: 1, Flags: FlagAdmin | FlagNoScript, Categories: []string{"admin", "slow", "dangerous"}}, HandlerFunc(srv.serveMonitor))
+	srv.builtin(CommandInfo{Name: "pubsub", Arity: -2, Flags: FlagPubSub, Categories: []string{"pubsub", "slow"}}, srv.pubsubRouter())
+	return srv
 }
 
 // Addr returns the server TCP address
@@ -44,42 +100,78 @@ func (srv *Server) Socket() string {
 	return srv.config.Socket
 }
 
+// TLSAddr returns the server TLS address
+func (srv *Server) TLSAddr() string {
+	return srv.config.TLSAddr
+}
+
 // Info returns the server info registry
 func (srv *Server) Info() *ServerInfo {
 	return srv.info
 }
 
-// Close shuts down the server and closes all connections
-func (srv *Server) Close() (err error) {
+// Close immediately shuts down the server and closes all connections.
+// For a graceful shutdown, use Shutdown.
+func (srv *Server) Close() error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.cancel()
 
-	// Stop new TCP connections
-	if srv.tcp != nil {
-		if e := srv.tcp.Close(); e != nil {
-			err = e
-		}
-		srv.tcp = nil
-	}
-
-	// Stop new Unix socket connections
-	if srv.unix != nil {
-		if e := srv.unix.Close(); e != nil {
-			err = e
-		}
-		srv.unix = nil
-	}
+	// Stop new connections
+	err := srv.closeListeners()
 
 	// Terminate all clients
-	if e := srv.clients.Clear(); err != nil {
+	if e := srv.clients.Clear(); e != nil {
 		err = e
 	}
+	return err
+}
 
-	return
+// Shutdown gracefully shuts down the server without interrupting any
+// active commands. It stops accepting new connections, closes idle clients
+// and waits for active clients to finish their current command and flush
+// the reply before closing them. When the context expires first, all
+// remaining connections are closed forcefully and the context's error
+// is returned.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+
+	// Request contexts stay alive while draining
+	defer srv.cancel()
+
+	// Stop new connections
+	err := srv.closeListeners()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if srv.clients.CloseIdle() == 0 {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			srv.cancel()
+			_ = srv.clients.Clear()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 }
 
 // Handle registers a handler for a command.
 // Not thread-safe, don't call from multiple goroutines
 func (srv *Server) Handle(name string, handler Handler) {
-	srv.commands[strings.ToLower(name)] = handler
+	srv.HandleCommand(CommandInfo{Name: name}, handler)
+}
+
+// HandleCommand registers a handler for a command, along with its
+// metadata. The number of arguments is validated against the arity before
+// the handler is invoked.
+// Not thread-safe, don't call from multiple goroutines
+func (srv *Server) HandleCommand(info CommandInfo, handler Handler) {
+	info.Name = strings.ToLower(info.Name)
+	srv.commands[info.Name] = newCommand(info, handler)
 }
 
 // HandleFunc registers a handler callback for a command
@@ -87,24 +179,51 @@ func (srv *Server) HandleFunc(name string, callback HandlerFunc) {
 	srv.Handle(name, Handler(callback))
 }
 
+// Use appends middleware to the server chain which runs for every
+// dispatched request, including unknown commands. Middleware runs in the
+// order it was added, before any per-command middleware applied via Chain.
+// Not thread-safe, don't call from multiple goroutines
+func (srv *Server) Use(middleware ...Middleware) {
+	srv.middleware = append(srv.middleware, middleware...)
+}
+
 // ListenAndServe starts the server
-func (srv *Server) ListenAndServe() (err error) {
-	errs := make(chan error, 2)
+func (srv *Server) ListenAndServe() error {
+	errs := make(chan error, 3)
+
+	// Closes already opened listeners when a later one fails
+	var opened []net.Listener
+	abort := func(err error) error {
+		for _, lis := range opened {
+			_ = lis.Close()
+		}
+		return err
+	}
 
 	if srv.Addr() != "" {
-		srv.tcp, err = net.Listen("tcp", srv.Addr())
+		tcp, err := net.Listen("tcp", srv.Addr())
 		if err != nil {
-			return
+			return abort(err)
 		}
-		go func() { errs <- srv.Serve(srv.tcp) }()
+		opened = append(opened, tcp)
+		go func() { errs <- srv.Serve(tcp) }()
 	}
 
 	if srv.Socket() != "" {
-		srv.unix, err = srv.listenUnix()
+		unix, err := srv.listenUnix()
 		if err != nil {
-			return err
+			return abort(err)
+		}
+		opened = append(opened, unix)
+		go func() { errs <- srv.Serve(unix) }()
+	}
+
+	if srv.TLSAddr() != "" {
+		tcp, err := net.Listen("tcp", srv.TLSAddr())
+		if err != nil {
+			return abort(err)
 		}
-		go func() { errs <- srv.Serve(srv.unix) }()
+		go func() { errs <- srv.ServeTLS(tcp) }()
 	}
 
 	return <-errs
@@ -112,82 +231,452 @@ func (srv *Server) ListenAndServe() (err error) {
 
 // ------------------------------------------------------------------------
 
+// Replied to clients when a handler panics
+var errPanic = ClientError("internal error")
+
+// Replied when the command timeout expires
+var errTimeout = ClientError("command timed out")
+
+// Replies to unknown commands
+var unknownCommand = HandlerFunc(func(_ *Responder, req *Request) error {
+	return req.UnknownCommand()
+})
+
+// Registers a built-in command
+func (srv *Server) builtin(info CommandInfo, handler Handler) *command {
+	cmd := newCommand(info, handler)
+	srv.builtins[info.Name] = cmd
+	return cmd
+}
+
+// Finds a command, registered handlers take precedence over
+// built-in commands
+func (srv *Server) lookup(name string) (*command, bool) {
+	if cmd, ok := srv.commands[name]; ok {
+		return cmd, true
+	}
+	cmd, ok := srv.builtins[name]
+	return cmd, ok
+}
+
+// Reports if the final argument of a command should be streamed
+func (srv *Server) streaming(name string) bool {
+	cmd, ok := srv.lookup(name)
+	return ok && cmd.info.Streaming
+}
+
 // Applies a request. Returns true when we should continue the client connection
 func (srv *Server) apply(req *Request, w io.Writer) bool {
 	res := NewResponder(w)
-	cmd, ok := srv.commands[req.Name]
-	if !ok {
-		res.WriteError(UnknownCommand(req.Name))
-		_ = res.release()
+	if req.client != nil {
+		res.proto = req.client.Protocol()
+	}
+
+	// RESP2 clients are restricted in subscribed mode
+	if res.proto < RESP3 && req.client != nil && req.client.subscriptions() != 0 && !subscribedModeCommands[req.Name] {
+		res.WriteErrorString("ERR Can't execute '" + req.Name + "': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+		_ = releaseReply(res, req)
 		return true
 	}
 
-	srv.info.onCommand()
-	if req.client != nil {
-		req.client.trackCommand(req.Name)
+	// Monitoring clients only receive the feed
+	if req.client != nil && req.client.Flags()&ClientMonitor != 0 && !monitorModeCommands[req.Name] {
+		res.WriteErrorString("ERR Can't execute '" + req.Name + "': only QUIT / RESET are allowed in MONITOR mode")
+		_ = releaseReply(res, req)
+		return true
 	}
 
-	err := cmd.ServeClient(res, req)
-	if res.buf.Len() == 0 {
+	cmd, ok := srv.lookup(req.Name)
+	if !ok || !cmd.info.ByteArgs {
+		req.deriveArgs()
+	}
+	if ok && cmd.info.Streaming {
+		req.detachStream()
+	}
+
+	argc := req.NumArgs()
+	if req.stream != nil {
+		argc++
+	}
+
+	// Commands are queued within MULTI
+	queue := req.client != nil && !txCommands[req.Name] && req.client.inMulti()
+
+	var handler Handler
+	if !ok {
+		handler = unknownCommand
+	} else if !cmd.info.acceptsArgs(argc) {
+		handler = wrongNumberOfArgs
+	} else if msg := srv.authorize(req.client, cmd, req); msg != "" {
+		handler = errorReply(msg)
+	} else if queue {
+		err := srv.queueCommand(res, req)
 		if err != nil {
 			res.WriteError(err)
-		} else {
+		}
+		return releaseReply(res, req) == nil
+	} else if !srv.waitUnpaused(req, cmd) {
+		_ = res.release()
+		return false
+	} else {
+		handler = cmd.handler
+
+		srv.info.onCommand()
+		if req.client != nil {
+			req.client.trackCommand(req.Name)
+		}
+		srv.feedMonitors(req, cmd)
+	}
+	if queue {
+		// Invalid commands abort the transaction
+		req.client.failMulti()
+	}
+	if len(srv.middleware) != 0 {
+		handler = Chain(handler, srv.middleware...)
+	}
+	if timeout := srv.config.CommandTimeout; timeout > 0 {
+		req.deadline = time.Now().Add(timeout)
+	}
+
+	var start time.Time
+	if srv.config.OnCommand != nil {
+		start = time.Now()
+	}
+
+	err := srv.invoke(handler, res, req)
+	if req.timedOut() && res.buf.Len() == 0 && !res.flushed {
+		err = errTimeout
+	}
+	req.releaseContext()
+
+	if fn := srv.config.OnCommand; fn != nil {
+		fn(req, time.Since(start), err)
+	}
+
+	if err == errPanic && res.flushed {
+		// A partial reply was already sent, disconnect
+		_ = res.release()
+		return false
+	}
+	if res.buf.Len() == 0 {
+		switch err {
+		case nil:
 			res.WriteOK()
+		case ErrBlockTimeout:
+			res.WriteNull()
+		case ErrUnblocked:
+			res.WriteErrorString("UNBLOCKED " + string(ErrUnblocked))
+		default:
+			res.WriteError(err)
 		}
 	}
-	return res.release() == nil
+	return releaseReply(res, req) == nil
+}
+
+// Releases the responder, discarding the reply if the client turned
+// replies off. Fails if the reply exceeds the output buffer limit
+func releaseReply(res *Responder, req *Request) error {
+	if req.client != nil && !req.inTx {
+		if req.client.skipReply() {
+			res.buf.Reset()
+		} else if !req.client.reserveOutput(res.buf.Len()) {
+			res.buf.Reset()
+			_ = res.release()
+			return errOutputLimit
+		}
+	}
+	return res.release()
+}
+
+// Invokes a handler, recovering from panics
+func (srv *Server) invoke(handler Handler, res *Responder, req *Request) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			srv.info.onPanic()
+			if fn := srv.config.OnPanic; fn != nil {
+				fn(req, v, debug.Stack())
+			}
+			res.buf.Reset()
+			err = errPanic
+		}
+	}()
+	return handler.ServeClient(res, req)
 }
 
 // Serve accepts incoming connections on a listener, creating a
-// new service goroutine for each.
+// new service goroutine for each. After Shutdown or Close, the returned
+// error is ErrServerClosed.
 func (srv *Server) Serve(lis net.Listener) error {
 	defer lis.Close()
 
+	if !srv.trackListener(lis, true) {
+		return ErrServerClosed
+	}
+	defer srv.trackListener(lis, false)
+
 	for {
 		conn, err := lis.Accept()
 		if err != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
 			return err
 		}
 		go srv.serveClient(NewClient(conn))
 	}
 }
 
+// Size of the per-client reply buffer, replies are flushed when full
+const replyBufferSize = 16 * 1024
+
+// Maximum interval between checks for idle clients
+const reapInterval = time.Second
+
 // Starts a new session, serving client
 func (srv *Server) serveClient(client *Client) {
-	// Register client
-	srv.clients.Put(client)
+	// Cancel requests when the client disconnects
+	client.ctx, client.cancel = context.WithCancel(srv.ctx)
+	client.rd = &connReader{conn: client.conn, cancel: client.cancel, timeout: srv.config.ReadTimeout}
+	client.wr = &connWriter{conn: client.conn, timeout: srv.config.WriteTimeout}
+	client.limits = srv.config.outputBufferLimits()
+	client.overflow = srv.info.onOutputLimit
+	defer client.cancel()
+
+	// Reserve a client slot, unless rejected
+	client.user = srv.defaultUser()
+	if err := srv.admit(client); err != nil {
+		srv.info.onReject()
+		reject(client.conn, err)
+		return
+	}
 	defer srv.clients.Close(client.id)
+	defer srv.pubsub.UnsubscribeAll(client)
+	defer srv.watches.Unwatch(client)
+	defer srv.monitors.Remove(client)
+
+	// Reject connections accepted during shutdown
+	if srv.shuttingDown() {
+		return
+	}
 
 	// Track connection
 	srv.info.onConnect()
+	srv.startReaper()
 
 	// Apply TCP keep-alive, if configured
-	if alive := srv.config.TCPKeepAlive; alive > 0 {
-		if tcpconn, ok := client.conn.(*net.TCPConn); ok {
-			tcpconn.SetKeepAlive(true)
-			tcpconn.SetKeepAlivePeriod(alive)
+	srv.setKeepAlive(client.conn)
+
+	// Complete TLS handshakes before serving
+	if err := srv.handshake(client.conn); err != nil {
+		return
+	}
+
+	// Run connection hooks
+	if fn := srv.config.OnConnect; fn != nil {
+		if err := fn(client); err != nil {
+			srv.info.onReject()
+			reject(client.conn, err)
+			return
 		}
 	}
+	if fn := srv.config.OnDisconnect; fn != nil {
+		defer func() { fn(client, srv.disconnectReason(client)) }()
+	}
+
+	// Register accepted client
+	srv.clients.Put(client)
 
 	// Init request/response loop
-	reader := bufio.NewReader(client.conn)
-	for {
-		if timeout := srv.config.Timeout; timeout > 0 {
-			client.conn.SetDeadline(time.Now().Add(timeout))
-		}
+	reader := newRequestReader(bufio.NewReader(client.rd))
+	reader.streaming = srv.streaming
+	reader.limits = srv.config.requestLimits()
+	defer reader.release()
 
-		req, err := ParseRequest(reader)
-		if err != nil {
-			NewResponder(client.conn).WriteError(err)
+	// Replies are buffered while more requests are pipelined
+	writer := bufio.NewWriterSize(client.wr, replyBufferSize)
+	client.out = writer
+	depth := 0
+
+	for {
+		req, err := srv.readRequest(client, reader, depth == 0)
+		if perr, ok := err.(ProtocolError); ok {
+			srv.info.onProtocolError()
+			client.disconnecting(DisconnectProtocolError)
+
+			w := NewResponder(writer)
+			w.WriteErrorString("ERR " + perr.Error())
+			_ = w.release()
+			_ = client.endCommand(writer)
+			return
+		} else if err != nil {
+			// Client disconnected or timed out
+			if isTimeout(err) {
+				srv.info.onTimeout(1)
+				client.disconnecting(DisconnectTimeout)
+			}
+			_ = client.endCommand(writer)
 			return
 		}
 		req.client = client
+		client.touch()
+
+		depth++
+
+		client.rd.enabled = reader.rd.Buffered() == 0 && reader.stream == nil
+		ok := srv.apply(req, writer)
+		client.rd.abortPendingRead()
+		client.touch()
+		client.trackBuffers(reader.rd.Buffered(), writer.Buffered())
+		done := !ok || client.quit || srv.shuttingDown()
+
+		// Flush once all pipelined requests are served
+		if done || !reader.hasRequest() {
+			srv.info.onPipeline(depth)
+			depth = 0
+
+			err := client.endCommand(writer)
+			client.trackBuffers(reader.rd.Buffered(), writer.Buffered())
+			if err != nil {
+				if isTimeout(err) {
+					srv.info.onTimeout(1)
+					client.disconnecting(DisconnectTimeout)
+				}
+				return
+			} else if done {
+				return
+			}
+		}
+	}
+}
+
+// Returns the cause of a client disconnect
+func (srv *Server) disconnectReason(client *Client) DisconnectReason {
+	if reason := client.disconnectReason(); reason != 0 {
+		return reason
+	} else if srv.shuttingDown() {
+		return DisconnectShutdown
+	}
+	return DisconnectEOF
+}
+
+// Maximum time to spend replying to rejected connections
+const rejectTimeout = time.Second
 
-		ok := srv.apply(req, client.conn)
-		if !ok || client.quit {
+// Admits a client connection and reserves a slot for it, unless the
+// admission hook or the client limits reject it
+func (srv *Server) admit(client *Client) error {
+	if fn := srv.config.Admit; fn != nil {
+		if err := fn(client.conn); err != nil {
+			return err
+		}
+	}
+	return srv.clients.Reserve(client, srv.config.MaxClients, srv.config.MaxClientsPerIP)
+}
+
+// Replies with an error and closes a rejected connection
+func reject(conn net.Conn, err error) {
+	_ = conn.SetDeadline(time.Now().Add(rejectTimeout))
+
+	w := NewResponder(conn)
+	w.WriteError(err)
+	_ = w.release()
+	_ = conn.Close()
+}
+
+// Waits for the next request. The read timeout applies once the first
+// byte has been received, idle clients are reaped separately
+func (srv *Server) readRequest(client *Client, reader *requestReader, idle bool) (*Request, error) {
+	client.rd.reading = false
+	if _, err := reader.rd.Peek(1); err != nil {
+		return nil, err
+	}
+
+	// Clients are busy as soon as a request starts to arrive
+	if idle && !client.beginCommand() {
+		return nil, io.EOF
+	}
+
+	client.rd.reading = true
+	return reader.Next()
+}
+
+// Starts closing idle clients in the background, if configured
+func (srv *Server) startReaper() {
+	timeout := srv.config.idleTimeout()
+	if timeout <= 0 {
+		return
+	}
+
+	srv.reaper.Do(func() {
+		interval := reapInterval
+		if timeout < 10*interval {
+			interval = timeout / 10
+		}
+		go srv.reapIdle(timeout, interval)
+	})
+}
+
+// Closes idle clients periodically, until the server is closed
+func (srv *Server) reapIdle(timeout, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-srv.ctx.Done():
 			return
+		case <-ticker.C:
+			if n := srv.clients.CloseTimedOut(timeout); n != 0 {
+				srv.info.onTimeout(n)
+			}
+		}
+	}
+}
+
+// Applies TCP keep-alive, if configured
+func (srv *Server) setKeepAlive(conn net.Conn) {
+	if alive := srv.config.TCPKeepAlive; alive > 0 {
+		if tcpconn, ok := conn.(*net.TCPConn); ok {
+			tcpconn.SetKeepAlive(true)
+			tcpconn.SetKeepAlivePeriod(alive)
+		}
+	}
+}
+
+// Returns true when Shutdown or Close was called
+func (srv *Server) shuttingDown() bool {
+	return atomic.LoadInt32(&srv.inShutdown) != 0
+}
+
+// Adds or removes a listener, returns false when
+// the server is shutting down
+func (srv *Server) trackListener(lis net.Listener, add bool) bool {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	if !add {
+		delete(srv.listeners, lis)
+		return true
+	}
+	if srv.shuttingDown() {
+		return false
+	}
+	srv.listeners[lis] = struct{}{}
+	return true
+}
+
+// Closes all tracked listeners
+func (srv *Server) closeListeners() (err error) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	for lis := range srv.listeners {
+		if e := lis.Close(); e != nil {
+			err = e
 		}
+		delete(srv.listeners, lis)
 	}
+	return
 }
 
 // listenUnix starts the unix listener on socket path
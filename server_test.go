@@ -1,10 +1,13 @@
 package redeo
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"io"
 	"net"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -42,6 +45,15 @@ var _ = Describe("Server", func() {
 		Expect(subject.config).To(Equal(DefaultConfig))
 	})
 
+	It("should close listeners when listening fails", func() {
+		subject = NewServer(&Config{Addr: "127.0.0.1:9737", Socket: "/non/existent/redeo.sock"})
+		Expect(subject.ListenAndServe()).To(HaveOccurred())
+
+		lis, err := net.Listen("tcp", "127.0.0.1:9737")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lis.Close()).To(Succeed())
+	})
+
 	It("should listen/serve/close", func() {
 		subject.HandleFunc("pInG", pong)
 
@@ -83,6 +95,645 @@ var _ = Describe("Server", func() {
 		Expect(err).To(Equal(io.EOF))
 	})
 
+	It("should reply with protocol errors and disconnect", func() {
+		subject = NewServer(&Config{MaxBulkLen: 4})
+
+		cn, sn := net.Pipe()
+		defer cn.Close()
+		go subject.serveClient(NewClient(sn))
+
+		go cn.Write([]byte("*2\r\n$4\r\necho\r\n$5\r\nhello\r\n"))
+		rd := bufio.NewReader(cn)
+		Expect(rd.ReadString('\n')).To(Equal("-ERR Protocol error: invalid bulk length\r\n"))
+		_, err := rd.ReadByte()
+		Expect(err).To(Equal(io.EOF))
+
+		Expect(subject.Info().TotalProtocolErrors()).To(Equal(int64(1)))
+		Expect(subject.Info().String()).To(ContainSubstring("total_protocol_errors:1\n"))
+	})
+
+	It("should batch replies to pipelined requests", func() {
+		subject.HandleFunc("ping", pong)
+
+		cn, sn := net.Pipe()
+		defer cn.Close()
+		go subject.serveClient(NewClient(sn))
+
+		go cn.Write([]byte("PING\r\nPING\r\n*1\r\n$4\r\nping\r\n"))
+		buf := make([]byte, 64)
+		n, err := cn.Read(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf[:n])).To(Equal("+PONG\r\n+PONG\r\n+PONG\r\n"))
+
+		go cn.Write([]byte("PING\r\n"))
+		n, err = cn.Read(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf[:n])).To(Equal("+PONG\r\n"))
+
+		Expect(subject.Info().AvgPipelineDepth()).To(Equal(2.0))
+		Expect(subject.Info().String()).To(ContainSubstring("avg_pipeline_depth:2.00\n"))
+	})
+
+	It("should send flushed data while handlers are running", func() {
+		resume := make(chan struct{})
+		subject.HandleFunc("ping", pong)
+		subject.HandleFunc("stream", func(out *Responder, _ *Request) error {
+			out.WriteBulkLen(2)
+			out.WriteInlineString("a")
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			<-resume
+			out.WriteInlineString("b")
+			return nil
+		})
+
+		cn, sn := net.Pipe()
+		defer cn.Close()
+		go subject.serveClient(NewClient(sn))
+		rd := bufio.NewReader(cn)
+
+		go cn.Write([]byte("PING\r\nSTREAM\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("*2\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("+a\r\n"))
+		close(resume)
+		Expect(rd.ReadString('\n')).To(Equal("+b\r\n"))
+	})
+
+	It("should flush pipelined replies before blocking", func() {
+		subject.HandleFunc("ping", pong)
+		subject.HandleFunc("blpop", func(out *Responder, req *Request) error {
+			w := subject.Block(req, 0, req.Args...)
+			defer w.Close()
+
+			key, err := w.Wait()
+			if err != nil {
+				return err
+			}
+			out.WriteString(key)
+			return nil
+		})
+		subject.HandleCommand(CommandInfo{Name: "set", Arity: 3, Flags: FlagWrite}, HandlerFunc(func(out *Responder, _ *Request) error {
+			out.WriteOK()
+			return nil
+		}))
+
+		cn, sn := net.Pipe()
+		defer cn.Close()
+		go subject.serveClient(NewClient(sn))
+		rd := bufio.NewReader(cn)
+
+		go cn.Write([]byte("PING\r\nBLPOP k 0\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+		Eventually(subject.blocking.Len).Should(Equal(1))
+		Expect(subject.Signal("k")).To(BeTrue())
+		Expect(rd.ReadString('\n')).To(Equal("$1\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("k\r\n"))
+
+		subject.pause.Set(time.Now().Add(time.Minute), false)
+		go cn.Write([]byte("PING\r\nSET k v\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+		subject.pause.Clear()
+		Expect(rd.ReadString('\n')).To(Equal("+OK\r\n"))
+	})
+
+	Describe("request context", func() {
+		var cn net.Conn
+		var cancelled chan error
+
+		var serve = func(config *Config) {
+			done := make(chan error, 1)
+			cancelled = done
+
+			subject = NewServer(config)
+			subject.HandleFunc("block", func(out *Responder, req *Request) error {
+				<-req.Context().Done()
+				done <- req.Context().Err()
+				return req.Context().Err()
+			})
+			subject.HandleFunc("ping", pong)
+
+			var sn net.Conn
+			cn, sn = net.Pipe()
+			go subject.serveClient(NewClient(sn))
+		}
+
+		AfterEach(func() {
+			cn.Close()
+		})
+
+		It("should cancel on client disconnect", func() {
+			serve(nil)
+			_, err := cn.Write([]byte("BLOCK\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(cancelled).ShouldNot(Receive())
+
+			Expect(cn.Close()).To(Succeed())
+			Eventually(cancelled).Should(Receive(Equal(context.Canceled)))
+		})
+
+		It("should cancel on client disconnect after the read timeout", func() {
+			serve(&Config{ReadTimeout: 20 * time.Millisecond})
+			_, err := cn.Write([]byte("BLO"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = cn.Write([]byte("CK\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(cancelled, "60ms").ShouldNot(Receive())
+
+			Expect(cn.Close()).To(Succeed())
+			Eventually(cancelled).Should(Receive(Equal(context.Canceled)))
+		})
+
+		It("should cancel on server close", func() {
+			serve(nil)
+			_, err := cn.Write([]byte("BLOCK\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(subject.Close()).To(Succeed())
+			Eventually(cancelled).Should(Receive(Equal(context.Canceled)))
+		})
+
+		It("should continue reading after watching for disconnects", func() {
+			serve(&Config{CommandTimeout: 50 * time.Millisecond})
+			rd := bufio.NewReader(cn)
+
+			go cn.Write([]byte("BLOCK\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("-ERR command timed out\r\n"))
+			Expect(cancelled).To(Receive(Equal(context.DeadlineExceeded)))
+
+			go cn.Write([]byte("PING\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+		})
+	})
+
+	Describe("hooks", func() {
+		var cn net.Conn
+		var client *Client
+		var reasons chan DisconnectReason
+
+		var serve = func(config *Config) {
+			var sn net.Conn
+			cn, sn = net.Pipe()
+			client = NewClient(sn)
+
+			own, done := client, make(chan DisconnectReason, 1)
+			reasons = done
+			config.OnDisconnect = func(c *Client, reason DisconnectReason) {
+				if c == own {
+					done <- reason
+				}
+			}
+
+			subject = NewServer(config)
+			subject.HandleFunc("ping", pong)
+			subject.HandleFunc("ctx", func(out *Responder, req *Request) error {
+				out.WriteString(req.Client().Ctx.(string))
+				return nil
+			})
+
+			go subject.serveClient(client)
+		}
+
+		AfterEach(func() {
+			cn.Close()
+			subject.Close()
+		})
+
+		It("should invoke connection hooks", func() {
+			serve(&Config{OnConnect: func(c *Client) error {
+				c.Ctx = "session"
+				return nil
+			}})
+			rd := bufio.NewReader(cn)
+
+			go cn.Write([]byte("CTX\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("$7\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("session\r\n"))
+			Consistently(reasons).ShouldNot(Receive())
+
+			Expect(cn.Close()).To(Succeed())
+			Eventually(reasons).Should(Receive(Equal(DisconnectEOF)))
+		})
+
+		It("should register clients once accepted", func() {
+			listed := make(chan int, 1)
+			serve(&Config{OnConnect: func(c *Client) error {
+				listed <- subject.Info().ClientsLen()
+				return nil
+			}})
+			Expect(<-listed).To(Equal(0))
+			Eventually(subject.Info().ClientsLen).Should(Equal(1))
+		})
+
+		It("should reject connections", func() {
+			serve(&Config{OnConnect: func(c *Client) error {
+				return ClientError("not welcome")
+			}})
+			rd := bufio.NewReader(cn)
+
+			Expect(rd.ReadString('\n')).To(Equal("-ERR not welcome\r\n"))
+			Eventually(subject.Info().ClientsLen).Should(Equal(0))
+			Expect(subject.Info().TotalRejectedConnections()).To(Equal(int64(1)))
+			Consistently(reasons).ShouldNot(Receive())
+		})
+
+		It("should report protocol errors", func() {
+			serve(&Config{})
+			rd := bufio.NewReader(cn)
+
+			go cn.Write([]byte("*x\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("-ERR Protocol error: invalid multibulk length\r\n"))
+			Eventually(reasons).Should(Receive(Equal(DisconnectProtocolError)))
+		})
+
+		It("should report timeouts", func() {
+			serve(&Config{ReadTimeout: 20 * time.Millisecond})
+
+			_, err := cn.Write([]byte("*2\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(reasons).Should(Receive(Equal(DisconnectTimeout)))
+		})
+
+		It("should report killed clients", func() {
+			serve(&Config{})
+			Eventually(subject.Info().ClientsLen).Should(Equal(1))
+
+			subject.killClient(nil, client)
+			Eventually(reasons).Should(Receive(Equal(DisconnectKilled)))
+		})
+
+		It("should report shutdowns", func() {
+			serve(&Config{})
+			Eventually(subject.Info().ClientsLen).Should(Equal(1))
+
+			Expect(subject.Close()).To(Succeed())
+			Eventually(reasons).Should(Receive(Equal(DisconnectShutdown)))
+		})
+
+		It("should observe commands", func() {
+			type outcome struct {
+				name    string
+				elapsed time.Duration
+				err     error
+			}
+			outcomes := make(chan outcome, 10)
+
+			serve(&Config{OnCommand: func(req *Request, elapsed time.Duration, err error) {
+				outcomes <- outcome{name: req.Name, elapsed: elapsed, err: err}
+			}})
+			subject.HandleFunc("fail", failing)
+			subject.HandleFunc("sleep", func(out *Responder, _ *Request) error {
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			})
+			rd := bufio.NewReader(cn)
+
+			go cn.Write([]byte("PING\r\nFAIL\r\nSLEEP\r\nUNKNOWN\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("-ERR EOF\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("+OK\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("-ERR unknown command 'unknown'\r\n"))
+
+			var o outcome
+			Expect(outcomes).To(Receive(&o))
+			Expect(o.name).To(Equal("ping"))
+			Expect(o.err).NotTo(HaveOccurred())
+			Expect(outcomes).To(Receive(&o))
+			Expect(o.name).To(Equal("fail"))
+			Expect(o.err).To(Equal(io.EOF))
+			Expect(outcomes).To(Receive(&o))
+			Expect(o.name).To(Equal("sleep"))
+			Expect(o.elapsed).To(BeNumerically(">=", 20*time.Millisecond))
+			Expect(outcomes).To(Receive(&o))
+			Expect(o.name).To(Equal("unknown"))
+			Expect(o.err).To(Equal(UnknownCommand("unknown")))
+		})
+	})
+
+	Describe("timeouts", func() {
+		var cn net.Conn
+
+		var serve = func(config *Config) {
+			subject = NewServer(config)
+			subject.HandleFunc("ping", pong)
+
+			var sn net.Conn
+			cn, sn = net.Pipe()
+			go subject.serveClient(NewClient(sn))
+		}
+
+		AfterEach(func() {
+			cn.Close()
+			subject.Close()
+		})
+
+		It("should close idle clients", func() {
+			serve(&Config{IdleTimeout: 50 * time.Millisecond})
+			rd := bufio.NewReader(cn)
+
+			go cn.Write([]byte("PING\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+
+			_, err := rd.ReadByte()
+			Expect(err).To(Equal(io.EOF))
+			Expect(subject.Info().TotalTimeouts()).To(Equal(int64(1)))
+		})
+
+		It("should not close slow commands or subscribed clients", func() {
+			serve(&Config{IdleTimeout: 50 * time.Millisecond})
+			subject.HandleFunc("sleep", func(out *Responder, _ *Request) error {
+				time.Sleep(100 * time.Millisecond)
+				out.WriteInt(1)
+				return nil
+			})
+			rd := bufio.NewReader(cn)
+
+			go cn.Write([]byte("SLEEP\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal(":1\r\n"))
+
+			go cn.Write([]byte("SUBSCRIBE a\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("*3\r\n"))
+			Consistently(subject.Info().ClientsLen, "150ms").Should(Equal(1))
+			Expect(subject.Info().TotalTimeouts()).To(Equal(int64(0)))
+		})
+
+		It("should time out slow requests", func() {
+			serve(&Config{ReadTimeout: 50 * time.Millisecond})
+
+			_, err := cn.Write([]byte("*2\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(subject.Info().TotalTimeouts).Should(Equal(int64(1)))
+		})
+
+		It("should not time out while waiting for requests", func() {
+			serve(&Config{ReadTimeout: 20 * time.Millisecond, WriteTimeout: 20 * time.Millisecond})
+			rd := bufio.NewReader(cn)
+
+			time.Sleep(50 * time.Millisecond)
+			go cn.Write([]byte("PING\r\n"))
+			Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+		})
+
+		It("should time out slow readers", func() {
+			serve(&Config{WriteTimeout: 50 * time.Millisecond})
+
+			_, err := cn.Write([]byte("PING\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(subject.Info().TotalTimeouts).Should(Equal(int64(1)))
+		})
+	})
+
+	It("should track client traffic", func() {
+		subject.HandleFunc("ping", pong)
+		client := NewClient(nil)
+		cn, sn := net.Pipe()
+		client.conn = sn
+		go subject.serveClient(client)
+		defer cn.Close()
+
+		rd := bufio.NewReader(cn)
+		go cn.Write([]byte("PING\r\nPING\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+
+		Eventually(client.TotalCommands).Should(Equal(int64(2)))
+		Expect(client.BytesRead()).To(Equal(int64(12)))
+		Eventually(client.BytesWritten).Should(Equal(int64(14)))
+		Expect(client.QueryBufferLen()).To(Equal(0))
+		Expect(client.OutputBufferLen()).To(Equal(0))
+	})
+
+	It("should disconnect slow subscribers", func() {
+		subject = NewServer(&Config{OutputBufferLimits: OutputBufferLimits{
+			PubSub: OutputBufferLimit{Hard: 1024},
+		}})
+		subject.HandleFunc("ping", pong)
+		cn, sn := net.Pipe()
+		go subject.serveClient(NewClient(sn))
+		defer cn.Close()
+
+		rd := bufio.NewReader(cn)
+		go cn.Write([]byte("SUBSCRIBE a\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("*3\r\n"))
+		Eventually(func() int { return subject.pubsub.NumSub("a") }).Should(Equal(1))
+
+		payload := make([]byte, 100)
+		for i := 0; i < 20; i++ {
+			subject.Publish("a", payload)
+		}
+		Eventually(subject.Info().ClientsLen).Should(Equal(0))
+		Expect(subject.Info().TotalOutputLimitDisconnections()).To(Equal(int64(1)))
+		Expect(subject.Info().String()).To(ContainSubstring("client_output_buffer_limit_disconnections:1\n"))
+	})
+
+	It("should apply default output buffer limits", func() {
+		Expect((&Config{}).outputBufferLimits()).To(Equal(&defaultOutputBufferLimits))
+		Expect(DefaultConfig.outputBufferLimits().PubSub.Hard).To(Equal(int64(32 << 20)))
+		Expect((&Config{DisableOutputBufferLimits: true}).outputBufferLimits()).To(BeNil())
+
+		partial := (&Config{OutputBufferLimits: OutputBufferLimits{
+			Normal: OutputBufferLimit{Hard: 10},
+		}}).outputBufferLimits()
+		Expect(partial).To(Equal(&OutputBufferLimits{
+			Normal:  OutputBufferLimit{Hard: 10},
+			Replica: defaultOutputBufferLimits.Replica,
+			PubSub:  defaultOutputBufferLimits.PubSub,
+		}))
+
+		unlimited := (&Config{OutputBufferLimits: OutputBufferLimits{
+			PubSub: OutputBufferLimit{Hard: -1},
+		}}).outputBufferLimits()
+		Expect(unlimited.PubSub).To(Equal(OutputBufferLimit{}))
+		Expect(unlimited.Replica).To(Equal(defaultOutputBufferLimits.Replica))
+	})
+
+	It("should disconnect clients with oversized replies", func() {
+		subject = NewServer(&Config{OutputBufferLimits: OutputBufferLimits{
+			Normal: OutputBufferLimit{Hard: 10},
+		}})
+		subject.HandleFunc("ping", pong)
+		subject.HandleFunc("big", func(out *Responder, _ *Request) error {
+			out.WriteString("a very long reply")
+			return nil
+		})
+		cn, sn := net.Pipe()
+		go subject.serveClient(NewClient(sn))
+		defer cn.Close()
+
+		rd := bufio.NewReader(cn)
+		go cn.Write([]byte("PING\r\nBIG\r\n"))
+		Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+		_, err := rd.ReadByte()
+		Expect(err).To(Equal(io.EOF))
+		Expect(subject.Info().TotalOutputLimitDisconnections()).To(Equal(int64(1)))
+	})
+
+	It("should reject clients above the limit", func() {
+		subject = NewServer(&Config{MaxClients: 1})
+		subject.HandleFunc("ping", pong)
+
+		cn1, sn1 := net.Pipe()
+		go subject.serveClient(NewClient(sn1))
+		defer cn1.Close()
+		Eventually(subject.Info().ClientsLen).Should(Equal(1))
+
+		cn2, sn2 := net.Pipe()
+		go subject.serveClient(NewClient(sn2))
+		defer cn2.Close()
+
+		rd := bufio.NewReader(cn2)
+		Expect(rd.ReadString('\n')).To(Equal("-ERR max number of clients reached\r\n"))
+		_, err := rd.ReadByte()
+		Expect(err).To(Equal(io.EOF))
+		Expect(subject.Info().ClientsLen()).To(Equal(1))
+		Expect(subject.Info().TotalConnections()).To(Equal(int64(1)))
+		Expect(subject.Info().TotalRejectedConnections()).To(Equal(int64(1)))
+		Expect(subject.Info().String()).To(ContainSubstring("rejected_connections:1\n"))
+	})
+
+	It("should reject clients via admission hook", func() {
+		subject = NewServer(&Config{Admit: func(conn net.Conn) error {
+			return ClientError("connection not allowed")
+		}})
+
+		cn, sn := net.Pipe()
+		go subject.serveClient(NewClient(sn))
+		defer cn.Close()
+
+		rd := bufio.NewReader(cn)
+		Expect(rd.ReadString('\n')).To(Equal("-ERR connection not allowed\r\n"))
+		Expect(subject.Info().TotalRejectedConnections()).To(Equal(int64(1)))
+	})
+
+	It("should disconnect silently on truncated requests", func() {
+		cn, sn := net.Pipe()
+		go subject.serveClient(NewClient(sn))
+
+		_, err := cn.Write([]byte("*2\r\n$4\r\necho\r\n$5\r\nhel"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cn.Close()).To(Succeed())
+		Eventually(subject.Info().ClientsLen).Should(Equal(0))
+		Expect(subject.Info().TotalProtocolErrors()).To(Equal(int64(0)))
+	})
+
+	Describe("Shutdown", func() {
+		var lis net.Listener
+		var served chan error
+		var started, release chan struct{}
+
+		var dial = func() net.Conn {
+			conn, err := net.Dial("tcp", lis.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			return conn
+		}
+
+		BeforeEach(func() {
+			var err error
+			lis, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+
+			startedC, releaseC := make(chan struct{}, 1), make(chan struct{})
+			started, release = startedC, releaseC
+
+			subject.HandleFunc("ping", pong)
+			subject.HandleFunc("slow", func(out *Responder, req *Request) error {
+				startedC <- struct{}{}
+				<-releaseC
+				if err := req.Context().Err(); err != nil {
+					return err
+				}
+				out.WriteInlineString("DONE")
+				return nil
+			})
+
+			srv, l, servedC := subject, lis, make(chan error, 1)
+			served = servedC
+			go func() { servedC <- srv.Serve(l) }()
+		})
+
+		It("should drain active clients", func() {
+			idle, active := dial(), dial()
+			defer idle.Close()
+			defer active.Close()
+
+			rd := bufio.NewReader(idle)
+			_, err := idle.Write([]byte("PING\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rd.ReadString('\n')).To(Equal("+PONG\r\n"))
+
+			_, err = active.Write([]byte("SLOW\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(started).Should(Receive())
+
+			done := make(chan error, 1)
+			go func() { done <- subject.Shutdown(context.Background()) }()
+
+			// Idle connections are closed first
+			_, err = rd.ReadString('\n')
+			Expect(err).To(Equal(io.EOF))
+			Expect(<-served).To(Equal(ErrServerClosed))
+			Consistently(done).ShouldNot(Receive())
+
+			// Active connections receive their reply
+			close(release)
+			rd = bufio.NewReader(active)
+			Expect(rd.ReadString('\n')).To(Equal("+DONE\r\n"))
+			Eventually(done).Should(Receive(BeNil()))
+
+			_, err = rd.ReadString('\n')
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("should wait for partially received requests", func() {
+			partial := dial()
+			defer partial.Close()
+
+			_, err := partial.Write([]byte("*1\r\n$4\r\npi"))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(func() int64 {
+				clients := subject.clients.All()
+				if len(clients) == 0 {
+					return 0
+				}
+				return clients[0].BytesRead()
+			}).Should(BeNumerically(">", 0))
+
+			done := make(chan error, 1)
+			go func() { done <- subject.Shutdown(context.Background()) }()
+			Consistently(done).ShouldNot(Receive())
+
+			_, err = partial.Write([]byte("ng\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bufio.NewReader(partial).ReadString('\n')).To(Equal("+PONG\r\n"))
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("should force-close when the context expires", func() {
+			active := dial()
+			defer active.Close()
+			defer close(release)
+
+			_, err := active.Write([]byte("SLOW\r\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(started).Should(Receive())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			Expect(subject.Shutdown(ctx)).To(Equal(context.DeadlineExceeded))
+
+			_, err = bufio.NewReader(active).ReadString('\n')
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("should not serve after shutdown", func() {
+			Expect(subject.Shutdown(context.Background())).To(Succeed())
+			Expect(<-served).To(Equal(ErrServerClosed))
+			Expect(subject.Serve(lis)).To(Equal(ErrServerClosed))
+		})
+
+	})
+
 	It("should register handlers", func() {
 		subject.HandleFunc("pInG", pong)
 		Expect(subject.commands).To(HaveLen(1))
@@ -116,6 +767,81 @@ var _ = Describe("Server", func() {
 			Expect(subject.Info().TotalCommands()).To(Equal(int64(3)))
 		})
 
+		It("should skip string conversion for ByteArgs commands", func() {
+			var args []string
+			var arg []byte
+			subject.HandleCommand(CommandInfo{Name: "raw", Arity: 2, ByteArgs: true}, HandlerFunc(func(out *Responder, req *Request) error {
+				args, arg = req.Args, req.Arg(0)
+				out.WriteInt(len(arg))
+				return nil
+			}))
+
+			w := &bytes.Buffer{}
+			ok := subject.apply(&Request{Name: "raw", argv: [][]byte{[]byte("x\x00y")}}, w)
+			Expect(ok).To(BeTrue())
+			Expect(w.String()).To(Equal(":3\r\n"))
+			Expect(args).To(BeNil())
+			Expect(arg).To(Equal([]byte("x\x00y")))
+		})
+
+		It("should stream final arguments", func() {
+			subject.HandleCommand(CommandInfo{Name: "put", Arity: 3, Streaming: true}, HandlerFunc(func(out *Responder, req *Request) error {
+				stream, n := req.Stream()
+				out.WriteN(stream, n)
+				return nil
+			}))
+
+			w := &bytes.Buffer{}
+			ok := subject.apply(&Request{Name: "put", Args: []string{"k", "value"}}, w)
+			Expect(ok).To(BeTrue())
+			Expect(w.String()).To(Equal("$5\r\nvalue\r\n"))
+
+			w = &bytes.Buffer{}
+			ok = subject.apply(&Request{Name: "put", Args: []string{"k"}}, w)
+			Expect(ok).To(BeTrue())
+			Expect(w.String()).To(Equal("-ERR wrong number of arguments for 'put' command\r\n"))
+		})
+
+		It("should reply with timeout errors", func() {
+			subject = NewServer(&Config{CommandTimeout: time.Millisecond})
+			subject.HandleFunc("slow", func(out *Responder, req *Request) error {
+				<-req.Context().Done()
+				return nil
+			})
+			subject.HandleFunc("sleep", func(out *Responder, req *Request) error {
+				time.Sleep(5 * time.Millisecond)
+				return nil
+			})
+			subject.HandleFunc("ignore", func(out *Responder, req *Request) error {
+				<-req.Context().Done()
+				out.WriteInt(1)
+				return nil
+			})
+
+			w := &bytes.Buffer{}
+			Expect(subject.apply(&Request{Name: "slow"}, w)).To(BeTrue())
+			Expect(w.String()).To(Equal("-ERR command timed out\r\n"))
+
+			w = &bytes.Buffer{}
+			Expect(subject.apply(&Request{Name: "sleep"}, w)).To(BeTrue())
+			Expect(w.String()).To(Equal("-ERR command timed out\r\n"))
+
+			w = &bytes.Buffer{}
+			Expect(subject.apply(&Request{Name: "ignore"}, w)).To(BeTrue())
+			Expect(w.String()).To(Equal(":1\r\n"))
+
+			subs := NewSubCommands()
+			subs.HandleFunc("slow", func(out *Responder, req *Request) error {
+				<-req.Context().Done()
+				return nil
+			})
+			subject.Handle("cfg", subs)
+
+			w = &bytes.Buffer{}
+			Expect(subject.apply(&Request{Name: "cfg", Args: []string{"slow"}}, w)).To(BeTrue())
+			Expect(w.String()).To(Equal("-ERR command timed out\r\n"))
+		})
+
 		It("should write errors if they occur", func() {
 			subject.HandleFunc("failing", failing)
 
@@ -134,6 +860,107 @@ var _ = Describe("Server", func() {
 			Expect(w.String()).To(Equal("+OK\r\n"))
 		})
 
+		It("should negotiate protocols via HELLO", func() {
+			client := NewClient(&mockConn{})
+
+			w := &bytes.Buffer{}
+			ok := subject.apply(&Request{Name: "hello", Args: []string{"3"}, client: client}, w)
+			Expect(ok).To(BeTrue())
+			Expect(client.Protocol()).To(Equal(RESP3))
+			Expect(w.String()).To(HavePrefix("%6\r\n$6\r\nserver\r\n$5\r\nredeo\r\n$5\r\nproto\r\n:3\r\n"))
+
+			w = &bytes.Buffer{}
+			ok = subject.apply(&Request{Name: "hello", Args: []string{"2"}, client: client}, w)
+			Expect(ok).To(BeTrue())
+			Expect(client.Protocol()).To(Equal(RESP2))
+			Expect(w.String()).To(HavePrefix("*12\r\n"))
+
+			w = &bytes.Buffer{}
+			subject.apply(&Request{Name: "hello", Args: []string{"4"}, client: client}, w)
+			Expect(w.String()).To(Equal("-NOPROTO unsupported protocol version\r\n"))
+
+			w = &bytes.Buffer{}
+			subject.apply(&Request{Name: "hello", Args: []string{"x"}, client: client}, w)
+			Expect(w.String()).To(Equal("-ERR Protocol version is not an integer or out of range\r\n"))
+		})
+
+		It("should prefer registered handlers over built-ins", func() {
+			subject.HandleFunc("hello", pong)
+
+			w := &bytes.Buffer{}
+			subject.apply(&Request{Name: "hello"}, w)
+			Expect(w.String()).To(Equal("+PONG\r\n"))
+		})
+
+		It("should run middleware", func() {
+			var seen []string
+			subject.Use(func(next Handler) Handler {
+				return HandlerFunc(func(out *Responder, req *Request) error {
+					seen = append(seen, "outer:"+req.Name)
+					return next.ServeClient(out, req)
+				})
+			}, func(next Handler) Handler {
+				return HandlerFunc(func(out *Responder, req *Request) error {
+					seen = append(seen, "inner:"+req.Name)
+					if req.Name == "denied" {
+						out.WriteErrorString("NOPERM denied")
+						return nil
+					}
+					return next.ServeClient(out, req)
+				})
+			})
+			subject.HandleFunc("ping", pong)
+
+			w := &bytes.Buffer{}
+			subject.apply(&Request{Name: "ping"}, w)
+			Expect(w.String()).To(Equal("+PONG\r\n"))
+
+			w = &bytes.Buffer{}
+			subject.apply(&Request{Name: "unknown"}, w)
+			Expect(w.String()).To(Equal("-ERR unknown command 'unknown'\r\n"))
+
+			w = &bytes.Buffer{}
+			subject.apply(&Request{Name: "denied"}, w)
+			Expect(w.String()).To(Equal("-NOPERM denied\r\n"))
+
+			Expect(seen).To(Equal([]string{
+				"outer:ping", "inner:ping",
+				"outer:unknown", "inner:unknown",
+				"outer:denied", "inner:denied",
+			}))
+		})
+
+		It("should recover from panics", func() {
+			var recovered interface{}
+			var stack []byte
+			subject = NewServer(&Config{OnPanic: func(_ *Request, v interface{}, s []byte) {
+				recovered, stack = v, s
+			}})
+			subject.HandleFunc("panic", func(out *Responder, _ *Request) error {
+				out.WriteBulkLen(2)
+				panic("oops")
+			})
+			subject.HandleFunc("stream", func(out *Responder, _ *Request) error {
+				out.WriteN(strings.NewReader("partial"), 7)
+				panic("oops")
+			})
+
+			w := &bytes.Buffer{}
+			ok := subject.apply(&Request{Name: "panic"}, w)
+			Expect(ok).To(BeTrue())
+			Expect(w.String()).To(Equal("-ERR internal error\r\n"))
+			Expect(recovered).To(Equal("oops"))
+			Expect(string(stack)).To(ContainSubstring("server_test.go"))
+			Expect(subject.Info().TotalPanics()).To(Equal(int64(1)))
+			Expect(subject.Info().String()).To(ContainSubstring("total_panics_recovered:1\n"))
+
+			w = &bytes.Buffer{}
+			ok = subject.apply(&Request{Name: "stream"}, w)
+			Expect(ok).To(BeFalse())
+			Expect(w.String()).To(Equal("$7\r\npartial"))
+			Expect(subject.Info().TotalPanics()).To(Equal(int64(2)))
+		})
+
 		It("should return false on write failures", func() {
 			subject.HandleFunc("blank", blank)
 
@@ -0,0 +1,103 @@
+package redeo
+
+import (
+	"sort"
+	"strings"
+)
+
+// SubCommands routes container commands, such as CLIENT LIST or CONFIG GET,
+// to handlers by their first argument. Subcommand names are matched
+// case-insensitively. SubCommands implement Handler and are registered
+// on the server as a regular command:
+//
+//	config := redeo.NewSubCommands()
+//	config.HandleFunc("get", configGet)
+//	srv.Handle("config", config)
+//
+// Subcommand handlers receive the request with the subcommand name
+// stripped from the arguments. A <CMD> HELP reply is generated
+// automatically.
+type SubCommands struct {
+	subs map[string]*command
+}
+
+// NewSubCommands creates a new subcommand router
+func NewSubCommands() *SubCommands {
+	return &SubCommands{subs: make(map[string]*command)}
+}
+
+// Handle registers a handler for a subcommand.
+// Not thread-safe, don't call from multiple goroutines
+func (s *SubCommands) Handle(name string, handler Handler) {
+	s.HandleCommand(CommandInfo{Name: name}, handler)
+}
+
+// HandleFunc registers a handler callback for a subcommand
+func (s *SubCommands) HandleFunc(name string, callback HandlerFunc) {
+	s.Handle(name, Handler(callback))
+}
+
+// HandleCommand registers a handler for a subcommand, along with its
+// metadata. The arity includes the container command and the subcommand
+// name, e.g. -3 for CONFIG GET parameter [parameter ...]. The Summary is
+// included in the HELP output.
+// Not thread-safe, don't call from multiple goroutines
+func (s *SubCommands) HandleCommand(info CommandInfo, handler Handler) {
+	info.Name = strings.ToLower(info.Name)
+	s.subs[info.Name] = newCommand(info, handler)
+}
+
+// ServeClient implements Handler
+func (s *SubCommands) ServeClient(out *Responder, req *Request) error {
+	argc := req.NumArgs()
+	if argc == 0 {
+		return req.WrongNumberOfArgs()
+	}
+
+	name := string(req.Arg(0))
+	sub, ok := s.subs[strings.ToLower(name)]
+	if !ok {
+		if strings.ToLower(name) == "help" && argc == 1 {
+			s.writeHelp(out, req.Name)
+			return nil
+		}
+		return ClientError("unknown subcommand '" + name + "'. Try " + strings.ToUpper(req.Name) + " HELP.")
+	}
+	if !sub.info.acceptsArgs(argc) {
+		return WrongNumberOfArgs(req.Name + "|" + sub.info.Name)
+	}
+
+	subreq := *req
+	subreq.parent = req
+	if req.Args != nil {
+		subreq.Args = req.Args[1:]
+	}
+	if req.argv != nil {
+		subreq.argv = req.argv[1:]
+	}
+	return sub.handler.ServeClient(out, &subreq)
+}
+
+// list returns all subcommands, sorted by name
+func (s *SubCommands) list() []*command {
+	subs := make([]*command, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	sort.Sort(commandSlice(subs))
+	return subs
+}
+
+func (s *SubCommands) writeHelp(out *Responder, name string) {
+	subs := s.list()
+	name = strings.ToUpper(name)
+
+	out.WriteBulkLen(2*len(subs) + 3)
+	out.WriteInlineString(name + " <subcommand> [<arg> [value] [opt] ...]. Subcommands are:")
+	for _, sub := range subs {
+		out.WriteInlineString(strings.ToUpper(sub.info.Name))
+		out.WriteInlineString("    " + sub.info.Summary)
+	}
+	out.WriteInlineString("HELP")
+	out.WriteInlineString("    Print this help.")
+}
@@ -0,0 +1,77 @@
+package redeo
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SubCommands", func() {
+	var subject *SubCommands
+
+	var serve = func(args ...string) string {
+		w := &bytes.Buffer{}
+		res := NewResponder(w)
+		if err := subject.ServeClient(res, &Request{Name: "config", Args: args}); err != nil {
+			res.WriteError(err)
+		}
+		Expect(res.Flush()).To(Succeed())
+		return w.String()
+	}
+
+	BeforeEach(func() {
+		subject = NewSubCommands()
+		subject.HandleCommand(CommandInfo{
+			Name:    "GET",
+			Arity:   -3,
+			Summary: "Return parameters matching the glob-like <pattern>.",
+		}, HandlerFunc(func(out *Responder, req *Request) error {
+			out.WriteStringBulk(req.Args)
+			return nil
+		}))
+		subject.HandleFunc("resetstat", func(out *Responder, _ *Request) error {
+			out.WriteOK()
+			return nil
+		})
+	})
+
+	It("should route subcommands", func() {
+		Expect(serve("get", "a", "b")).To(Equal("*2\r\n$1\r\na\r\n$1\r\nb\r\n"))
+		Expect(serve("GeT", "a")).To(Equal("*1\r\n$1\r\na\r\n"))
+		Expect(serve("resetstat")).To(Equal("+OK\r\n"))
+	})
+
+	It("should validate arguments", func() {
+		Expect(serve()).To(Equal("-ERR wrong number of arguments for 'config' command\r\n"))
+		Expect(serve("get")).To(Equal("-ERR wrong number of arguments for 'config|get' command\r\n"))
+		Expect(serve("set", "a")).To(Equal("-ERR unknown subcommand 'set'. Try CONFIG HELP.\r\n"))
+	})
+
+	It("should share the request context lazily", func() {
+		var ctx context.Context
+		subject.HandleFunc("ctx", func(out *Responder, req *Request) error {
+			ctx = req.Context()
+			return nil
+		})
+
+		req := &Request{Name: "config", Args: []string{"resetstat"}}
+		Expect(subject.ServeClient(NewResponder(&bytes.Buffer{}), req)).To(Succeed())
+		Expect(req.ctx).To(BeNil())
+
+		req.Args = []string{"ctx"}
+		Expect(subject.ServeClient(NewResponder(&bytes.Buffer{}), req)).To(Succeed())
+		Expect(ctx).NotTo(BeNil())
+		Expect(ctx).To(BeIdenticalTo(req.ctx))
+	})
+
+	It("should generate help", func() {
+		Expect(serve("help")).To(Equal("*7\r\n" +
+			"+CONFIG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:\r\n" +
+			"+GET\r\n+    Return parameters matching the glob-like <pattern>.\r\n" +
+			"+RESETSTAT\r\n+    \r\n" +
+			"+HELP\r\n+    Print this help.\r\n"))
+	})
+
+})
@@ -0,0 +1,78 @@
+package redeo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+)
+
+// ServeTLS accepts incoming TLS connections on a listener, creating a
+// new service goroutine for each. Connections are secured using
+// Config.TLSConfig.
+func (srv *Server) ServeTLS(lis net.Listener) error {
+	if srv.config.TLSConfig == nil {
+		_ = lis.Close()
+		return ErrNoTLSConfig
+	}
+	return srv.Serve(&tlsListener{Listener: lis, srv: srv})
+}
+
+// Performs the TLS handshake on TLS connections
+func (srv *Server) handshake(conn net.Conn) error {
+	tlsconn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	timeout := srv.config.ReadTimeout
+	if timeout <= 0 {
+		timeout = srv.config.idleTimeout()
+	}
+	if timeout <= 0 {
+		return tlsconn.Handshake()
+	}
+
+	tlsconn.SetDeadline(time.Now().Add(timeout))
+	defer tlsconn.SetDeadline(time.Time{})
+	return tlsconn.Handshake()
+}
+
+// tlsListener wraps accepted connections in TLS, after applying TCP
+// keep-alive to the underlying connection
+type tlsListener struct {
+	net.Listener
+	srv *Server
+}
+
+func (l *tlsListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.srv.setKeepAlive(conn)
+	return tls.Server(conn, l.srv.config.TLSConfig), nil
+}
+
+// ------------------------------------------------------------------------
+
+// TLSConnectionState returns the TLS connection state, returns false if
+// the client is not connected via TLS
+func (i *Client) TLSConnectionState() (tls.ConnectionState, bool) {
+	if tlsconn, ok := i.conn.(*tls.Conn); ok {
+		return tlsconn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+// PeerCertificate returns the verified client certificate. Returns nil if
+// the client is not connected via TLS or did not present a certificate
+// that could be verified against Config.TLSConfig.ClientCAs
+func (i *Client) PeerCertificate() *x509.Certificate {
+	state, ok := i.TLSConnectionState()
+	if !ok || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
@@ -0,0 +1,144 @@
+package redeo
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS", func() {
+	var subject *Server
+	var lis net.Listener
+	var conns []net.Conn
+	var ca *x509.Certificate
+	var caKey *ecdsa.PrivateKey
+
+	var dial = func(certs ...tls.Certificate) *bufio.ReadWriter {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca)
+
+		conn, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{
+			RootCAs:      pool,
+			ServerName:   "localhost",
+			Certificates: certs,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		conns = append(conns, conn)
+		return bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	}
+
+	var call = func(rw *bufio.ReadWriter, cmd string) string {
+		_, err := rw.WriteString(cmd + "\r\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rw.Flush()).To(Succeed())
+
+		line, err := rw.ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		return line
+	}
+
+	BeforeEach(func() {
+		var err error
+		ca, caKey, err = generateCert("Test CA", nil, nil, true)
+		Expect(err).NotTo(HaveOccurred())
+		serverCert, serverKey, err := generateCert("localhost", ca, caKey, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		pool := x509.NewCertPool()
+		pool.AddCert(ca)
+
+		subject = NewServer(&Config{TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+			ClientAuth:   tls.VerifyClientCertIfGiven,
+			ClientCAs:    pool,
+		}})
+		subject.HandleFunc("whoami", func(out *Responder, req *Request) error {
+			if cert := req.Client().PeerCertificate(); cert != nil {
+				out.WriteInlineString(cert.Subject.CommonName)
+			} else {
+				out.WriteNil()
+			}
+			return nil
+		})
+
+		lis, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		srv, l := subject, lis
+		go srv.ServeTLS(l)
+	})
+
+	AfterEach(func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		conns = conns[:0]
+		Expect(subject.Close()).To(Succeed())
+	})
+
+	It("should serve TLS connections", func() {
+		rw := dial()
+		Expect(call(rw, "WHOAMI")).To(Equal("$-1\r\n"))
+
+		clients := subject.Info().Clients()
+		Expect(clients).To(HaveLen(1))
+		state, ok := clients[0].TLSConnectionState()
+		Expect(ok).To(BeTrue())
+		Expect(state.HandshakeComplete).To(BeTrue())
+	})
+
+	It("should expose verified client certificates", func() {
+		cert, key, err := generateCert("alice", ca, caKey, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		rw := dial(tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key})
+		Expect(call(rw, "WHOAMI")).To(Equal("+alice\r\n"))
+	})
+
+	It("should require a TLS config", func() {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(NewServer(nil).ServeTLS(lis)).To(Equal(ErrNoTLSConfig))
+	})
+
+})
+
+func generateCert(name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = tpl, key
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, tpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(raw)
+	return cert, key, err
+}